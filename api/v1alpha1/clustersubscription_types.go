@@ -0,0 +1,74 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ClusterSubscriptionKind is the kind of ClusterSubscription.
+	ClusterSubscriptionKind = "ClusterSubscription"
+	// ClusterSubscriptionLabel is the label key for the name of cluster
+	// subscription that generated the resource.
+	ClusterSubscriptionLabel = "eventreactor.summerwind.dev/cluster-subscription"
+)
+
+// ClusterSubscriptionSpec defines the desired state of ClusterSubscription
+type ClusterSubscriptionSpec struct {
+	SubscriptionSpec `json:",inline"`
+
+	// NamespaceSelector selects the namespaces of events that the
+	// subscription reacts to. An empty selector selects all namespaces.
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector"`
+	// TargetNamespace is the template of the namespace in which resources
+	// are created, such as '(( .Event.Namespace ))-ci'. Defaults to the
+	// namespace of event.
+	// +optional
+	TargetNamespace string `json:"targetNamespace,omitempty"`
+	// TargetNamespaceSelector restricts the namespaces in which resources
+	// are created. Events are not dispatched if the target namespace does
+	// not match the selector, so that the namespace computed from events
+	// cannot be an arbitrary namespace.
+	TargetNamespaceSelector *metav1.LabelSelector `json:"targetNamespaceSelector"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+
+// ClusterSubscription is the Schema for the clustersubscriptions API
+type ClusterSubscription struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterSubscriptionSpec `json:"spec,omitempty"`
+	Status SubscriptionStatus      `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterSubscriptionList contains a list of ClusterSubscription
+type ClusterSubscriptionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterSubscription `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterSubscription{}, &ClusterSubscriptionList{})
+}
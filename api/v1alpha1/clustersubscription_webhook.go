@@ -0,0 +1,101 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	"github.com/summerwind/eventreactor/pkg/template"
+)
+
+func (r *ClusterSubscription) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-eventreactor-summerwind-dev-v1alpha1-clustersubscription,mutating=false,failurePolicy=fail,groups=eventreactor.summerwind.dev,resources=clustersubscriptions,versions=v1alpha1,name=vclustersubscription.kb.io
+
+var _ webhook.Validator = &ClusterSubscription{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *ClusterSubscription) ValidateCreate() error {
+	return r.validate()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *ClusterSubscription) ValidateUpdate(old runtime.Object) error {
+	return r.validate()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *ClusterSubscription) ValidateDelete() error {
+	return nil
+}
+
+func (r *ClusterSubscription) validate() error {
+	errs := ValidateClusterSubscriptionSpec(&r.Spec, field.NewPath("spec"))
+	if len(errs) > 0 {
+		return apierrors.NewInvalid(GroupVersion.WithKind(ClusterSubscriptionKind).GroupKind(), r.Name, errs)
+	}
+
+	return nil
+}
+
+// ValidateClusterSubscriptionSpec validates the namespace selectors and the
+// target namespace in addition to the spec of subscription. It is shared by
+// the webhook and the cluster subscription controller.
+func ValidateClusterSubscriptionSpec(spec *ClusterSubscriptionSpec, fldPath *field.Path) field.ErrorList {
+	errs := ValidateSubscriptionSpec(&spec.SubscriptionSpec, fldPath)
+
+	// ClusterSubscriptions have no namespace to find the ResourceTemplate.
+	if spec.TemplateRef != nil && spec.TemplateRef.Namespace == "" {
+		errs = append(errs, field.Required(fldPath.Child("templateRef", "namespace"), "namespace must be specified"))
+	}
+
+	if spec.NamespaceSelector == nil {
+		errs = append(errs, field.Required(fldPath.Child("namespaceSelector"), "namespaceSelector must be specified"))
+	} else {
+		errs = append(errs, metav1validation.ValidateLabelSelector(spec.NamespaceSelector, fldPath.Child("namespaceSelector"))...)
+	}
+
+	if spec.TargetNamespaceSelector == nil {
+		errs = append(errs, field.Required(fldPath.Child("targetNamespaceSelector"), "targetNamespaceSelector must be specified"))
+	} else {
+		errs = append(errs, metav1validation.ValidateLabelSelector(spec.TargetNamespaceSelector, fldPath.Child("targetNamespaceSelector"))...)
+	}
+
+	if spec.TargetNamespace != "" {
+		if err := template.Validate(spec.TargetNamespace); err != nil {
+			errs = append(errs, field.Invalid(fldPath.Child("targetNamespace"), spec.TargetNamespace, fmt.Sprintf("invalid template: %s", err)))
+		}
+
+		// Events cannot own resources in other namespaces.
+		if spec.Owner == OwnerEvent {
+			errs = append(errs, field.Forbidden(fldPath.Child("owner"), "owner cannot be Event with targetNamespace"))
+		}
+	}
+
+	return errs
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"math/rand"
 	"strings"
@@ -24,13 +25,61 @@ import (
 
 	"github.com/oklog/ulid/v2"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 var entropy *rand.Rand
 
+const (
+	// EventPhasePending means that the event has been accepted but not yet
+	// processed by the controller.
+	EventPhasePending = "Pending"
+	// EventPhaseDispatching means that the controller is dispatching the
+	// event to subscriptions.
+	EventPhaseDispatching = "Dispatching"
+	// EventPhaseSucceeded means that the event has been dispatched.
+	EventPhaseSucceeded = "Succeeded"
+	// EventPhaseFailed means that the event could not be dispatched.
+	EventPhaseFailed = "Failed"
+)
+
+const (
+	// DispatchFailedEventType is the type of event that is emitted when an
+	// event could not be dispatched to a subscription.
+	DispatchFailedEventType = "dev.summerwind.eventreactor.dispatch.failed"
+	// DeadLetterAnnotation is the annotation key for the namespaced name of
+	// original event of dead letter.
+	DeadLetterAnnotation = "eventreactor.summerwind.dev/dead-letter-of"
+	// PrincipalAnnotation is the annotation key for the authenticated
+	// principal that sent the event.
+	PrincipalAnnotation = "eventreactor.summerwind.dev/principal"
+	// DedupeKeyLabel is the label key for the hash of source and ID of
+	// event that is used to find duplicate events.
+	DedupeKeyLabel = "eventreactor.summerwind.dev/dedupe-key"
+)
+
+const (
+	// ResourceActionCreated means that the resource has been created.
+	ResourceActionCreated = "Created"
+	// ResourceActionUpdated means that the resource has been updated.
+	ResourceActionUpdated = "Updated"
+	// ResourceActionApplied means that the resource has been applied with
+	// server-side apply.
+	ResourceActionApplied = "Applied"
+	// ResourceActionSkipped means that the resource already exists and
+	// has not been changed.
+	ResourceActionSkipped = "Skipped"
+)
+
 // EventSpec defines the desired state of Event
 type EventSpec struct {
-	// ID specifies the unique ID of event.
+	// SpecVersion specifies the version of CloudEvents specification that
+	// the event uses. Defaults to 1.0.
+	// +optional
+	SpecVersion string `json:"specVersion,omitempty"`
+	// ID specifies the unique ID of event. A unique ID is generated if
+	// it is not specified.
+	// +optional
 	ID string `json:"id"`
 	// Source specifies the source of event.
 	Source string `json:"source"`
@@ -47,12 +96,57 @@ type EventSpec struct {
 	// +optional
 	Subject string `json:"subject,omitempty"`
 	// Time specifies the timestamp of when the occurrence happened.
+	// The time of creation is used if it is not specified.
 	// +optional
 	Time *metav1.Time `json:"time,omitempty"`
 
+	// Extensions specifies the extension attributes of event.
+	// +optional
+	Extensions map[string]string `json:"extensions,omitempty"`
+
 	// Data specifies the event payload.
 	// +optional
 	Data string `json:"data,omitempty"`
+	// DataBase64 specifies the base64 encoded event payload. It is used
+	// for binary payload instead of Data.
+	// +optional
+	DataBase64 string `json:"dataBase64,omitempty"`
+	// DataRef specifies the reference to the event payload that is stored
+	// outside of the Event. It is used for large payload instead of Data.
+	// +optional
+	DataRef *DataReference `json:"dataRef,omitempty"`
+}
+
+// Versions of CloudEvents specification.
+const (
+	SpecVersion10 = "1.0"
+	SpecVersion03 = "0.3"
+)
+
+// Data store types.
+const (
+	DataStoreConfigMap  = "ConfigMap"
+	DataStoreSecret     = "Secret"
+	DataStoreFileSystem = "FileSystem"
+)
+
+// DataReference represents a reference to the event payload in the data
+// store.
+type DataReference struct {
+	// Store specifies the type of data store.
+	// +kubebuilder:validation:Enum=ConfigMap;Secret;FileSystem
+	Store string `json:"store"`
+	// Namespace specifies the namespace of the data. The namespace of the
+	// event is used if it is not specified. Only dead letters created by
+	// the controller can specify another namespace.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// Name specifies the name of the data in the store. It must be a
+	// DNS-1123 subdomain.
+	Name string `json:"name"`
+	// Size specifies the size of the data in bytes.
+	// +optional
+	Size int64 `json:"size,omitempty"`
 }
 
 // EventStatus defines the observed state of Event
@@ -65,6 +159,56 @@ type EventStatus struct {
 	Message string `json:"message"`
 	// RFC 3339 date and time at which the object was acknowledged by the controller.
 	DispatchTime *metav1.Time `json:"dispatchTime,omitempty"`
+	// Subscriptions is the list of dispatch results of matched subscriptions.
+	// +optional
+	Subscriptions []SubscriptionResult `json:"subscriptions,omitempty"`
+}
+
+// SubscriptionResult represents the result of dispatching the event to a subscription.
+type SubscriptionResult struct {
+	// Kind is the kind of subscription, Subscription or
+	// ClusterSubscription. Defaults to Subscription.
+	// +optional
+	Kind string `json:"kind,omitempty"`
+	// Name is the name of subscription.
+	Name string `json:"name"`
+	// Phase is the phase of dispatching the event to the subscription.
+	// +optional
+	Phase string `json:"phase,omitempty"`
+	// Attempts is the number of dispatch attempts.
+	// +optional
+	Attempts int32 `json:"attempts,omitempty"`
+	// NextRetryTime is the time at which the next dispatch attempt will be made.
+	// +optional
+	NextRetryTime *metav1.Time `json:"nextRetryTime,omitempty"`
+	// Resources is the list of results of resource templates.
+	// +optional
+	Resources []ResourceResult `json:"resources,omitempty"`
+}
+
+// ResourceResult represents the result of applying a resource template.
+type ResourceResult struct {
+	// Template is the index of resource template in the subscription.
+	Template int `json:"template"`
+	// APIVersion is the API version of resource.
+	APIVersion string `json:"apiVersion"`
+	// Kind is the kind of resource.
+	Kind string `json:"kind"`
+	// Namespace is the namespace of resource.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// Name is the name of resource.
+	// +optional
+	Name string `json:"name,omitempty"`
+	// UID is the UID of resource.
+	// +optional
+	UID types.UID `json:"uid,omitempty"`
+	// Action is the action that was performed for the resource.
+	// +optional
+	Action string `json:"action,omitempty"`
+	// Error is the error message if the resource could not be applied.
+	// +optional
+	Error string `json:"error,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -96,6 +240,12 @@ func NewEventName() string {
 	return strings.ToLower(id.String())
 }
 
+// DedupeKey returns the key to identify the event by its source and ID. The
+// key is a hash so that it can be used as a label value.
+func DedupeKey(source, id string) string {
+	return fmt.Sprintf("%x", sha256.Sum224([]byte(source+"\x00"+id)))
+}
+
 func init() {
 	SchemeBuilder.Register(&Event{}, &EventList{})
 
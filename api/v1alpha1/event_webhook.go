@@ -0,0 +1,276 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// extensionNameRegexp is the pattern of the name of CloudEvents extension
+// attributes.
+var extensionNameRegexp = regexp.MustCompile("^[a-z0-9]+$")
+
+// DedupeWindow is the duration in which events with the same source and ID
+// are rejected as duplicates. Deduplication is disabled if it is zero.
+var DedupeWindow time.Duration
+
+// eventReader is the reader used to find duplicate events.
+var eventReader client.Reader
+
+func (r *Event) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	eventReader = mgr.GetClient()
+
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-eventreactor-summerwind-dev-v1alpha1-event,mutating=true,failurePolicy=fail,groups=eventreactor.summerwind.dev,resources=events,verbs=create,versions=v1alpha1,name=mevent.kb.io
+
+var _ webhook.Defaulter = &Event{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (r *Event) Default() {
+	if r.Spec.ID == "" {
+		r.Spec.ID = NewEventName()
+	}
+
+	if r.Spec.Time == nil {
+		now := metav1.Now()
+		r.Spec.Time = &now
+	}
+
+	if r.Labels == nil {
+		r.Labels = map[string]string{}
+	}
+	r.Labels[DedupeKeyLabel] = DedupeKey(r.Spec.Source, r.Spec.ID)
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-eventreactor-summerwind-dev-v1alpha1-event,mutating=false,failurePolicy=fail,groups=eventreactor.summerwind.dev,resources=events,versions=v1alpha1,name=vevent.kb.io
+
+var _ webhook.Validator = &Event{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *Event) ValidateCreate() error {
+	errs := validateEventSpec(&r.Spec, field.NewPath("spec"))
+	errs = append(errs, validateDataRefNamespace(r, field.NewPath("spec", "dataRef", "namespace"))...)
+	if len(errs) > 0 {
+		return apierrors.NewInvalid(GroupVersion.WithKind("Event").GroupKind(), r.Name, errs)
+	}
+
+	if eventReader != nil && DedupeWindow > 0 {
+		dup, err := FindDuplicateEvent(context.Background(), eventReader, r, DedupeWindow)
+		if err != nil {
+			return err
+		}
+		if dup != nil {
+			return fmt.Errorf("duplicate of event %s with the same source and id", dup.Name)
+		}
+	}
+
+	return nil
+}
+
+// FindDuplicateEvent returns the event that has the same source and ID as
+// the event and was created within the window. It returns nil if there is
+// no such event.
+func FindDuplicateEvent(ctx context.Context, c client.Reader, ev *Event, window time.Duration) (*Event, error) {
+	var eventList EventList
+
+	opts := []client.ListOption{
+		client.InNamespace(ev.Namespace),
+		client.MatchingLabels{DedupeKeyLabel: DedupeKey(ev.Spec.Source, ev.Spec.ID)},
+	}
+	if err := c.List(ctx, &eventList, opts...); err != nil {
+		return nil, err
+	}
+
+	since := time.Now().Add(-window)
+
+	var dup *Event
+	for i := range eventList.Items {
+		item := &eventList.Items[i]
+		if item.Name == ev.Name || item.CreationTimestamp.Time.Before(since) {
+			continue
+		}
+		// Labels may be set by users, so compare the attributes.
+		if item.Spec.Source != ev.Spec.Source || item.Spec.ID != ev.Spec.ID {
+			continue
+		}
+		// Return the oldest one in the window.
+		if dup == nil || item.CreationTimestamp.Before(&dup.CreationTimestamp) {
+			dup = item
+		}
+	}
+
+	return dup, nil
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *Event) ValidateUpdate(old runtime.Object) error {
+	oldEvent := old.(*Event)
+
+	if !apiequality.Semantic.DeepEqual(r.Spec, oldEvent.Spec) {
+		errs := field.ErrorList{
+			field.Forbidden(field.NewPath("spec"), "spec is immutable after creation"),
+		}
+		return apierrors.NewInvalid(GroupVersion.WithKind("Event").GroupKind(), r.Name, errs)
+	}
+
+	return nil
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *Event) ValidateDelete() error {
+	return nil
+}
+
+// ValidateDataReference validates the data reference of the event. It is
+// used by the controller as well as the webhook, since the webhook may be
+// disabled.
+func ValidateDataReference(ev *Event) field.ErrorList {
+	ref := ev.Spec.DataRef
+	if ref == nil {
+		return nil
+	}
+
+	refPath := field.NewPath("spec", "dataRef")
+	errs := validateDataRefName(ref, refPath.Child("name"))
+	return append(errs, validateDataRefNamespace(ev, refPath.Child("namespace"))...)
+}
+
+// validateDataRefName validates the name of data. The name must be a valid
+// object name so that it cannot refer to a path outside of the data store.
+func validateDataRefName(ref *DataReference, fldPath *field.Path) field.ErrorList {
+	if ref.Name == "" {
+		return field.ErrorList{field.Required(fldPath, "name must be specified")}
+	}
+
+	errs := field.ErrorList{}
+	for _, msg := range validation.IsDNS1123Subdomain(ref.Name) {
+		errs = append(errs, field.Invalid(fldPath, ref.Name, msg))
+	}
+
+	return errs
+}
+
+// validateDataRefNamespace validates the namespace of data. Events can refer
+// to the data in another namespace only if they are dead letters created in
+// the dead letter namespaces for the events in that namespace, so that
+// events cannot read the data of other namespaces.
+func validateDataRefNamespace(ev *Event, fldPath *field.Path) field.ErrorList {
+	ref := ev.Spec.DataRef
+	if ref == nil || ref.Namespace == "" || ref.Namespace == ev.Namespace {
+		return nil
+	}
+
+	source := ev.Annotations[DeadLetterAnnotation]
+	if !IsDeadLetterNamespace(ev.Namespace) || !strings.HasPrefix(source, ref.Namespace+"/") {
+		return field.ErrorList{field.Forbidden(fldPath, "namespace can be specified only by dead letters")}
+	}
+
+	return nil
+}
+
+// validateEventSpec validates the context attributes of CloudEvents.
+func validateEventSpec(spec *EventSpec, fldPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	switch spec.SpecVersion {
+	case "", SpecVersion10, SpecVersion03:
+	default:
+		errs = append(errs, field.NotSupported(fldPath.Child("specVersion"), spec.SpecVersion, []string{SpecVersion10, SpecVersion03}))
+	}
+
+	if spec.ID == "" {
+		errs = append(errs, field.Required(fldPath.Child("id"), "id must be specified"))
+	}
+
+	if spec.Source == "" {
+		errs = append(errs, field.Required(fldPath.Child("source"), "source must be specified"))
+	} else if _, err := url.Parse(spec.Source); err != nil {
+		errs = append(errs, field.Invalid(fldPath.Child("source"), spec.Source, "source must be a URI-reference"))
+	}
+
+	if spec.Type == "" {
+		errs = append(errs, field.Required(fldPath.Child("type"), "type must be specified"))
+	}
+
+	if spec.DataContentType != "" {
+		if _, _, err := mime.ParseMediaType(spec.DataContentType); err != nil {
+			errs = append(errs, field.Invalid(fldPath.Child("dataContentType"), spec.DataContentType, "dataContentType must be a media type"))
+		}
+	}
+
+	if spec.DataSchema != "" {
+		u, err := url.Parse(spec.DataSchema)
+		if err != nil || !u.IsAbs() {
+			errs = append(errs, field.Invalid(fldPath.Child("dataSchema"), spec.DataSchema, "dataSchema must be an absolute URI"))
+		}
+	}
+
+	if spec.DataBase64 != "" {
+		if spec.Data != "" {
+			errs = append(errs, field.Forbidden(fldPath.Child("dataBase64"), "data and dataBase64 cannot be specified at the same time"))
+		}
+		if _, err := base64.StdEncoding.DecodeString(spec.DataBase64); err != nil {
+			errs = append(errs, field.Invalid(fldPath.Child("dataBase64"), "(binary data)", "dataBase64 must be a base64 encoded string"))
+		}
+	}
+
+	if spec.DataRef != nil {
+		refPath := fldPath.Child("dataRef")
+		if spec.Data != "" || spec.DataBase64 != "" {
+			errs = append(errs, field.Forbidden(refPath, "dataRef cannot be specified with data or dataBase64"))
+		}
+		errs = append(errs, validateDataRefName(spec.DataRef, refPath.Child("name"))...)
+		switch spec.DataRef.Store {
+		case DataStoreConfigMap, DataStoreSecret, DataStoreFileSystem:
+		default:
+			errs = append(errs, field.NotSupported(refPath.Child("store"), spec.DataRef.Store, []string{DataStoreConfigMap, DataStoreSecret, DataStoreFileSystem}))
+		}
+	}
+
+	for name := range spec.Extensions {
+		if !extensionNameRegexp.MatchString(name) {
+			errs = append(errs, field.Invalid(fldPath.Child("extensions").Key(name), name, "extension name must consist of lower-case alphanumeric characters"))
+		}
+	}
+
+	if spec.Time != nil && spec.Time.IsZero() {
+		errs = append(errs, field.Invalid(fldPath.Child("time"), spec.Time, "time must be a RFC 3339 timestamp"))
+	}
+
+	return errs
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ResourceTemplateSpec defines the desired state of ResourceTemplate
+type ResourceTemplateSpec struct {
+	// Parameters is the list of parameters of the resource templates.
+	// Parameters are available as '.Params' in the resource templates.
+	// +optional
+	Parameters []ResourceTemplateParameter `json:"parameters,omitempty"`
+	// ResourceTemplates is the list of templates of resources that are
+	// created by the subscriptions that refer to the resource template.
+	// +kubebuilder:validation:MinItems=1
+	ResourceTemplates []unstructured.Unstructured `json:"resourceTemplates"`
+}
+
+// ResourceTemplateParameter describes a parameter of resource template.
+type ResourceTemplateParameter struct {
+	// Name is the name of parameter.
+	Name string `json:"name"`
+	// Description is the description of parameter.
+	// +optional
+	Description string `json:"description,omitempty"`
+	// Default is the default value of parameter.
+	// +optional
+	Default string `json:"default,omitempty"`
+	// Required specifies whether subscriptions must set the parameter.
+	// +optional
+	Required bool `json:"required,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ResourceTemplate is the Schema for the resourcetemplates API
+type ResourceTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ResourceTemplateSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ResourceTemplateList contains a list of ResourceTemplate
+type ResourceTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ResourceTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ResourceTemplate{}, &ResourceTemplateList{})
+}
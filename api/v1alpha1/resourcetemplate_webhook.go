@@ -0,0 +1,80 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+func (r *ResourceTemplate) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-eventreactor-summerwind-dev-v1alpha1-resourcetemplate,mutating=false,failurePolicy=fail,groups=eventreactor.summerwind.dev,resources=resourcetemplates,versions=v1alpha1,name=vresourcetemplate.kb.io
+
+var _ webhook.Validator = &ResourceTemplate{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *ResourceTemplate) ValidateCreate() error {
+	return r.validate()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *ResourceTemplate) ValidateUpdate(old runtime.Object) error {
+	return r.validate()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *ResourceTemplate) ValidateDelete() error {
+	return nil
+}
+
+func (r *ResourceTemplate) validate() error {
+	fldPath := field.NewPath("spec")
+	errs := field.ErrorList{}
+
+	paramPath := fldPath.Child("parameters")
+	names := map[string]bool{}
+	for i, param := range r.Spec.Parameters {
+		if param.Name == "" {
+			errs = append(errs, field.Required(paramPath.Index(i).Child("name"), "name must be specified"))
+			continue
+		}
+		if names[param.Name] {
+			errs = append(errs, field.Duplicate(paramPath.Index(i).Child("name"), param.Name))
+		}
+		names[param.Name] = true
+	}
+
+	tmplPath := fldPath.Child("resourceTemplates")
+	if len(r.Spec.ResourceTemplates) == 0 {
+		errs = append(errs, field.Required(tmplPath, "at least one resource template must be specified"))
+	}
+	errs = append(errs, validateResourceTemplates(r.Spec.ResourceTemplates, tmplPath)...)
+
+	if len(errs) > 0 {
+		return apierrors.NewInvalid(GroupVersion.WithKind("ResourceTemplate").GroupKind(), r.Name, errs)
+	}
+
+	return nil
+}
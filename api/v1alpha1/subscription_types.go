@@ -17,34 +17,412 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"errors"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
+// ApplyStrategy describes how generated resources are applied.
+// +kubebuilder:validation:Enum=Create;CreateOrUpdate;ServerSideApply;Patch
+type ApplyStrategy string
+
+const (
+	// ApplyStrategyCreate creates the resource only if it does not exist.
+	ApplyStrategyCreate ApplyStrategy = "Create"
+	// ApplyStrategyCreateOrUpdate creates the resource or replaces the
+	// existing resource with the generated one.
+	ApplyStrategyCreateOrUpdate ApplyStrategy = "CreateOrUpdate"
+	// ApplyStrategyServerSideApply applies the resource with server-side apply.
+	ApplyStrategyServerSideApply ApplyStrategy = "ServerSideApply"
+	// ApplyStrategyPatch patches the existing resource with the generated
+	// one or creates the resource if it does not exist.
+	ApplyStrategyPatch ApplyStrategy = "Patch"
+)
+
+// PatchType describes the type of patch used by Patch strategy.
+// +kubebuilder:validation:Enum=Merge;StrategicMerge
+type PatchType string
+
+const (
+	// PatchTypeMerge uses JSON merge patch.
+	PatchTypeMerge PatchType = "Merge"
+	// PatchTypeStrategicMerge uses strategic merge patch. It is only
+	// supported by built-in resources.
+	PatchTypeStrategicMerge PatchType = "StrategicMerge"
+)
+
+// NameStrategy describes how the names of generated resources are determined.
+// +kubebuilder:validation:Enum=Fixed;GenerateName;EventName;Template
+type NameStrategy string
+
+const (
+	// NameStrategyFixed uses the name of resource template. The name of
+	// subscription is used if the resource template has no name.
+	NameStrategyFixed NameStrategy = "Fixed"
+	// NameStrategyGenerateName generates a unique name for each event with
+	// the generateName of resource template or the fixed name as prefix.
+	NameStrategyGenerateName NameStrategy = "GenerateName"
+	// NameStrategyEventName uses the fixed name suffixed with the name of
+	// event.
+	NameStrategyEventName NameStrategy = "EventName"
+	// NameStrategyTemplate uses the result of name template.
+	NameStrategyTemplate NameStrategy = "Template"
+)
+
+// Owner describes the owner of generated resources.
+// +kubebuilder:validation:Enum=None;Event;Subscription
+type Owner string
+
+const (
+	// OwnerNone does not set owner reference to generated resources.
+	OwnerNone Owner = "None"
+	// OwnerEvent sets owner reference to the event so that generated
+	// resources are deleted with the event.
+	OwnerEvent Owner = "Event"
+	// OwnerSubscription sets owner reference to the subscription so that
+	// generated resources are deleted with the subscription.
+	OwnerSubscription Owner = "Subscription"
+)
+
+// Names longer than 63 characters are truncated with a hash suffix in the
+// values of labels.
+const (
+	// EventLabel is the label key for the name of event that generated
+	// the resource.
+	EventLabel = "eventreactor.summerwind.dev/event"
+	// SubscriptionLabel is the label key for the name of subscription that
+	// generated the resource.
+	SubscriptionLabel = "eventreactor.summerwind.dev/subscription"
+)
+
+const (
+	// TTLSecondsAfterDispatchAnnotation is the annotation key of namespace
+	// that specifies the default TTLSecondsAfterDispatch of events.
+	TTLSecondsAfterDispatchAnnotation = "eventreactor.summerwind.dev/ttl-seconds-after-dispatch"
+	// FailedTTLSecondsAfterDispatchAnnotation is the annotation key of
+	// namespace that specifies the default FailedTTLSecondsAfterDispatch
+	// of events.
+	FailedTTLSecondsAfterDispatchAnnotation = "eventreactor.summerwind.dev/failed-ttl-seconds-after-dispatch"
+	// MaxEventsPerTypeAnnotation is the annotation key of namespace that
+	// specifies the default MaxEventsPerType of events.
+	MaxEventsPerTypeAnnotation = "eventreactor.summerwind.dev/max-events-per-type"
+)
+
+// RetentionPolicy describes how long dispatched events are retained.
+type RetentionPolicy struct {
+	// TTLSecondsAfterDispatch limits the lifetime of an event after it has
+	// been dispatched. Events are retained forever if it is not specified.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	TTLSecondsAfterDispatch *int32 `json:"ttlSecondsAfterDispatch,omitempty"`
+	// FailedTTLSecondsAfterDispatch limits the lifetime of a failed event
+	// after it has been dispatched. Defaults to TTLSecondsAfterDispatch.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	FailedTTLSecondsAfterDispatch *int32 `json:"failedTTLSecondsAfterDispatch,omitempty"`
+	// MaxEventsPerType limits the number of succeeded events that have
+	// the same type in the namespace. The oldest events are deleted first.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxEventsPerType *int32 `json:"maxEventsPerType,omitempty"`
+}
+
+// RetryPolicy describes how failed dispatches are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of dispatch attempts. Defaults to 5.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxAttempts *int32 `json:"maxAttempts,omitempty"`
+	// BackoffBase is the delay before the first retry. The delay is doubled
+	// for each retry. Defaults to 5s.
+	// +optional
+	BackoffBase *metav1.Duration `json:"backoffBase,omitempty"`
+	// BackoffCap is the maximum delay between retries. Defaults to 5m.
+	// +optional
+	BackoffCap *metav1.Duration `json:"backoffCap,omitempty"`
+}
+
+// DeadLetterPolicy describes what to do with events that could not be
+// dispatched after all retries.
+type DeadLetterPolicy struct {
+	// Namespace is the namespace into which failed events are copied. It
+	// must be one of the namespaces allowed by the manager.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// EmitEvent specifies whether to emit an event of type
+	// dev.summerwind.eventreactor.dispatch.failed for failed events.
+	// +optional
+	EmitEvent bool `json:"emitEvent,omitempty"`
+}
+
 // SubscriptionSpec defines the desired state of Subscription
 type SubscriptionSpec struct {
 	Trigger SubscriptionSpecTrigger `json:"trigger"`
 	// +kubebuilder:validation:MinItems=1
 	//ResourceTemplates []runtime.RawExtension `json:"resourceTemplates,omitempty"`
 	ResourceTemplates []unstructured.Unstructured `json:"resourceTemplates,omitempty"`
+	// TemplateRef specifies the ResourceTemplate that is used instead of
+	// ResourceTemplates.
+	// +optional
+	TemplateRef *TemplateReference `json:"templateRef,omitempty"`
+	// Parameters specifies the values of parameters of the ResourceTemplate.
+	// Parameters are available as '.Params' in templates.
+	// +optional
+	Parameters map[string]string `json:"parameters,omitempty"`
+	// ApplyStrategy specifies how generated resources are applied.
+	// Defaults to CreateOrUpdate.
+	// +optional
+	ApplyStrategy ApplyStrategy `json:"applyStrategy,omitempty"`
+	// PatchType specifies the type of patch used by Patch strategy.
+	// Defaults to Merge.
+	// +optional
+	PatchType PatchType `json:"patchType,omitempty"`
+	// NameStrategy specifies how the names of generated resources are
+	// determined. Defaults to Fixed.
+	// +optional
+	NameStrategy NameStrategy `json:"nameStrategy,omitempty"`
+	// NameTemplate specifies the template of resource name that is used
+	// by Template strategy.
+	// +optional
+	NameTemplate string `json:"nameTemplate,omitempty"`
+	// Owner specifies the owner of generated resources. Generated resources
+	// are garbage collected when the owner is deleted. Defaults to None.
+	// +optional
+	Owner Owner `json:"owner,omitempty"`
+	// Retention specifies the retention policy of events that matched the
+	// subscription. The annotations of namespace are used as default.
+	// +optional
+	Retention *RetentionPolicy `json:"retention,omitempty"`
+	// RetryPolicy specifies how failed dispatches are retried.
+	// +optional
+	RetryPolicy *RetryPolicy `json:"retryPolicy,omitempty"`
+	// DeadLetter specifies what to do with events that could not be
+	// dispatched after all retries.
+	// +optional
+	DeadLetter *DeadLetterPolicy `json:"deadLetter,omitempty"`
+}
+
+// TemplateReference represents a reference to a ResourceTemplate.
+type TemplateReference struct {
+	// Namespace is the namespace of the ResourceTemplate. Subscriptions can
+	// refer only to the ResourceTemplate in their namespace, and it must be
+	// specified for ClusterSubscriptions.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// Name is the name of the ResourceTemplate.
+	Name string `json:"name"`
 }
 
 // SubscriptionSpecTrigger defines the trigger of Subscription
 type SubscriptionSpecTrigger struct {
-	Type string `json:"type"`
+	// Type is the type of events. It can be a prefix pattern that ends
+	// with '.*' such as 'com.github.*', or '*' that matches any type.
+	// +optional
+	Type string `json:"type,omitempty"`
+	// Types is the list of types of events in addition to Type. Events
+	// match if any of the types matches.
+	// +optional
+	Types []string `json:"types,omitempty"`
 	// +optional
 	MatchSource string `json:"matchSource"`
 	// +optional
 	MatchSubject string `json:"matchSubject"`
+	// MatchExtensions is the map of extension attribute names and regular
+	// expressions to match their values. Events without the extension
+	// attribute do not match.
+	// +optional
+	MatchExtensions map[string]string `json:"matchExtensions,omitempty"`
+	// Filters is the list of expressions that must be evaluated to true
+	// for events to match. Expressions are written in a subset of CEL and
+	// can refer to the context attributes, 'extensions' and the decoded
+	// payload as 'data', such as 'data.action in ["opened", "synchronize"]'.
+	// +optional
+	Filters []string `json:"filters,omitempty"`
+	// MatchFilters is the list of filters in the dialects of CloudEvents
+	// Subscriptions API. Events match if all the filters match.
+	// +optional
+	MatchFilters []EventFilter `json:"matchFilters,omitempty"`
+}
+
+// EventFilter is a filter in the dialects of CloudEvents Subscriptions API.
+// Exactly one of the dialects must be specified. Filters refer to context
+// attributes and extension attributes by their names, and attributes that
+// the event does not have do not match.
+type EventFilter struct {
+	// Exact matches if the value of the attribute is exactly the same as
+	// the specified value. It must have exactly one attribute.
+	// +optional
+	Exact map[string]string `json:"exact,omitempty"`
+	// Prefix matches if the value of the attribute starts with the
+	// specified value. It must have exactly one attribute.
+	// +optional
+	Prefix map[string]string `json:"prefix,omitempty"`
+	// Suffix matches if the value of the attribute ends with the specified
+	// value. It must have exactly one attribute.
+	// +optional
+	Suffix map[string]string `json:"suffix,omitempty"`
+	// All matches if all the nested filters match.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	All []EventFilter `json:"all,omitempty"`
+	// Any matches if any of the nested filters matches.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	Any []EventFilter `json:"any,omitempty"`
+	// Not matches if the nested filter does not match.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	Not *EventFilter `json:"not,omitempty"`
+	// SQL matches if the CloudEvents SQL expression is evaluated to true,
+	// such as "type LIKE 'com.github.%' AND subject = 'main'".
+	// +optional
+	SQL string `json:"sql,omitempty"`
+}
+
+// EventTypes returns the list of event types of the trigger.
+func (t *SubscriptionSpecTrigger) EventTypes() []string {
+	types := []string{}
+	if t.Type != "" {
+		types = append(types, t.Type)
+	}
+	return append(types, t.Types...)
+}
+
+// ValidateEventType returns an error if the event type of trigger is
+// invalid. Wildcards are allowed only as the last segment of the type.
+func ValidateEventType(pattern string) error {
+	if pattern == "" {
+		return errors.New("type must not be empty")
+	}
+
+	if i := strings.Index(pattern, "*"); i >= 0 {
+		if pattern != "*" && (i != len(pattern)-1 || !strings.HasSuffix(pattern, ".*") || pattern == ".*") {
+			return errors.New("wildcard must be used as '*' or the last segment such as 'com.example.*'")
+		}
+	}
+
+	return nil
+}
+
+// MatchEventType returns true if the event type matches the type of
+// trigger. 'x.y.*' matches the types that start with 'x.y.' and '*'
+// matches any type.
+func MatchEventType(pattern, eventType string) bool {
+	if pattern == "*" {
+		return true
+	}
+	if strings.HasSuffix(pattern, ".*") {
+		prefix := strings.TrimSuffix(pattern, "*")
+		return len(eventType) > len(prefix) && strings.HasPrefix(eventType, prefix)
+	}
+	return pattern == eventType
+}
+
+// FilterVariables is the list of variables available in the filters of
+// trigger. 'extensions' is the map of extension attributes and 'data' is
+// the decoded payload of event.
+var FilterVariables = []string{
+	"id",
+	"source",
+	"type",
+	"subject",
+	"time",
+	"datacontenttype",
+	"dataschema",
+	"extensions",
+	"data",
+}
+
+// SubscriptionConditionType is a valid value for SubscriptionCondition.Type
+type SubscriptionConditionType string
+
+const (
+	// SubscriptionReady means that the subscription has been validated and
+	// is ready to dispatch events.
+	SubscriptionReady SubscriptionConditionType = "Ready"
+	// SubscriptionInvalid means that the subscription has an invalid trigger
+	// or resource template.
+	SubscriptionInvalid SubscriptionConditionType = "Invalid"
+)
+
+// SubscriptionCondition describes the state of a subscription at a certain point.
+type SubscriptionCondition struct {
+	// Type of subscription condition.
+	Type SubscriptionConditionType `json:"type"`
+	// Status of the condition, one of True, False, Unknown.
+	Status corev1.ConditionStatus `json:"status"`
+	// Last time the condition transitioned from one status to another.
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// The reason for the condition's last transition.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// A human readable message indicating details about the transition.
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 // SubscriptionStatus defines the observed state of Subscription
 type SubscriptionStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// The generation observed by the subscription controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Represents the latest available observations of a subscription's current state.
+	// +optional
+	Conditions []SubscriptionCondition `json:"conditions,omitempty"`
+	// The generation of the referenced ResourceTemplate observed by the
+	// subscription controller.
+	// +optional
+	TemplateGeneration int64 `json:"templateGeneration,omitempty"`
+}
+
+// GetCondition returns the condition with the provided type.
+func (s *SubscriptionStatus) GetCondition(condType SubscriptionConditionType) *SubscriptionCondition {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == condType {
+			return &s.Conditions[i]
+		}
+	}
+
+	return nil
+}
+
+// SetCondition updates the condition with the provided type. The last
+// transition time is updated only if the status of condition is changed.
+func (s *SubscriptionStatus) SetCondition(condType SubscriptionConditionType, status corev1.ConditionStatus, reason, message string) {
+	cond := s.GetCondition(condType)
+	if cond == nil {
+		s.Conditions = append(s.Conditions, SubscriptionCondition{Type: condType})
+		cond = &s.Conditions[len(s.Conditions)-1]
+	}
+
+	if cond.Status != status {
+		cond.Status = status
+		cond.LastTransitionTime = metav1.Now()
+	}
+	cond.Reason = reason
+	cond.Message = message
+}
+
+// IsReady returns true if the subscription of the generation has been
+// validated and is ready to dispatch events.
+func (s *SubscriptionStatus) IsReady(generation int64) bool {
+	if s.ObservedGeneration != generation {
+		return false
+	}
+
+	cond := s.GetCondition(SubscriptionReady)
+	return cond != nil && cond.Status == corev1.ConditionTrue
 }
 
 // +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
 
 // Subscription is the Schema for the subscriptions API
 type Subscription struct {
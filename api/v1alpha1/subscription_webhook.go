@@ -0,0 +1,262 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"regexp"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	"github.com/summerwind/eventreactor/pkg/expr"
+	"github.com/summerwind/eventreactor/pkg/template"
+)
+
+// DeadLetterNamespaces is the list of namespaces into which failed events
+// can be copied by dead letter policies.
+var DeadLetterNamespaces []string
+
+// IsDeadLetterNamespace returns true if the namespace is allowed as the
+// destination of dead letters.
+func IsDeadLetterNamespace(namespace string) bool {
+	for _, ns := range DeadLetterNamespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *Subscription) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-eventreactor-summerwind-dev-v1alpha1-subscription,mutating=false,failurePolicy=fail,groups=eventreactor.summerwind.dev,resources=subscriptions,versions=v1alpha1,name=vsubscription.kb.io
+
+var _ webhook.Validator = &Subscription{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *Subscription) ValidateCreate() error {
+	return r.validate()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *Subscription) ValidateUpdate(old runtime.Object) error {
+	return r.validate()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *Subscription) ValidateDelete() error {
+	return nil
+}
+
+func (r *Subscription) validate() error {
+	fldPath := field.NewPath("spec")
+	errs := ValidateSubscriptionSpec(&r.Spec, fldPath)
+
+	if r.Spec.TemplateRef != nil && r.Spec.TemplateRef.Namespace != "" && r.Spec.TemplateRef.Namespace != r.Namespace {
+		errs = append(errs, field.Forbidden(fldPath.Child("templateRef", "namespace"), "templateRef cannot refer to other namespaces"))
+	}
+
+	if len(errs) > 0 {
+		return apierrors.NewInvalid(GroupVersion.WithKind("Subscription").GroupKind(), r.Name, errs)
+	}
+
+	return nil
+}
+
+// ValidateSubscriptionSpec validates the trigger, name template and
+// resource templates of subscription. It is shared by the webhook and the
+// subscription controller.
+func ValidateSubscriptionSpec(spec *SubscriptionSpec, fldPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	triggerPath := fldPath.Child("trigger")
+	if spec.Trigger.Type == "" && len(spec.Trigger.Types) == 0 {
+		errs = append(errs, field.Required(triggerPath.Child("type"), "type or types must be specified"))
+	}
+
+	if spec.Trigger.Type != "" {
+		if err := ValidateEventType(spec.Trigger.Type); err != nil {
+			errs = append(errs, field.Invalid(triggerPath.Child("type"), spec.Trigger.Type, err.Error()))
+		}
+	}
+
+	for i, t := range spec.Trigger.Types {
+		if err := ValidateEventType(t); err != nil {
+			errs = append(errs, field.Invalid(triggerPath.Child("types").Index(i), t, err.Error()))
+		}
+	}
+
+	if spec.Trigger.MatchSource != "" {
+		if _, err := regexp.Compile(spec.Trigger.MatchSource); err != nil {
+			errs = append(errs, field.Invalid(triggerPath.Child("matchSource"), spec.Trigger.MatchSource, err.Error()))
+		}
+	}
+
+	if spec.Trigger.MatchSubject != "" {
+		if _, err := regexp.Compile(spec.Trigger.MatchSubject); err != nil {
+			errs = append(errs, field.Invalid(triggerPath.Child("matchSubject"), spec.Trigger.MatchSubject, err.Error()))
+		}
+	}
+
+	for name, pattern := range spec.Trigger.MatchExtensions {
+		if !extensionNameRegexp.MatchString(name) {
+			errs = append(errs, field.Invalid(triggerPath.Child("matchExtensions").Key(name), name, "extension name must consist of lower-case alphanumeric characters"))
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			errs = append(errs, field.Invalid(triggerPath.Child("matchExtensions").Key(name), pattern, err.Error()))
+		}
+	}
+
+	for i, filter := range spec.Trigger.Filters {
+		if _, err := expr.Compile(filter, FilterVariables); err != nil {
+			errs = append(errs, field.Invalid(triggerPath.Child("filters").Index(i), filter, fmt.Sprintf("invalid filter: %s", err)))
+		}
+	}
+
+	for i := range spec.Trigger.MatchFilters {
+		errs = append(errs, validateEventFilter(&spec.Trigger.MatchFilters[i], triggerPath.Child("matchFilters").Index(i))...)
+	}
+
+	if spec.NameStrategy == NameStrategyTemplate && spec.NameTemplate == "" {
+		errs = append(errs, field.Required(fldPath.Child("nameTemplate"), "nameTemplate must be specified with Template strategy"))
+	}
+
+	if spec.NameTemplate != "" {
+		if err := template.Validate(spec.NameTemplate); err != nil {
+			errs = append(errs, field.Invalid(fldPath.Child("nameTemplate"), spec.NameTemplate, fmt.Sprintf("invalid template: %s", err)))
+		}
+	}
+
+	tmplPath := fldPath.Child("resourceTemplates")
+	if spec.TemplateRef != nil {
+		if len(spec.ResourceTemplates) > 0 {
+			errs = append(errs, field.Forbidden(tmplPath, "resourceTemplates cannot be specified with templateRef"))
+		}
+		if spec.TemplateRef.Name == "" {
+			errs = append(errs, field.Required(fldPath.Child("templateRef", "name"), "name must be specified"))
+		}
+	} else if len(spec.ResourceTemplates) == 0 {
+		errs = append(errs, field.Required(tmplPath, "at least one resource template or templateRef must be specified"))
+	}
+
+	errs = append(errs, validateResourceTemplates(spec.ResourceTemplates, tmplPath)...)
+
+	if spec.DeadLetter != nil && spec.DeadLetter.Namespace != "" && !IsDeadLetterNamespace(spec.DeadLetter.Namespace) {
+		errs = append(errs, field.Forbidden(fldPath.Child("deadLetter", "namespace"), fmt.Sprintf("namespace %q is not allowed for dead letters", spec.DeadLetter.Namespace)))
+	}
+
+	return errs
+}
+
+// validateEventFilter validates the filter and its nested filters.
+func validateEventFilter(filter *EventFilter, fldPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	dialects := 0
+	attrFilters := []struct {
+		name  string
+		attrs map[string]string
+	}{
+		{"exact", filter.Exact},
+		{"prefix", filter.Prefix},
+		{"suffix", filter.Suffix},
+	}
+
+	for _, f := range attrFilters {
+		if f.attrs == nil {
+			continue
+		}
+		dialects++
+
+		if len(f.attrs) != 1 {
+			errs = append(errs, field.Invalid(fldPath.Child(f.name), f.attrs, "exactly one attribute must be specified"))
+		}
+		for attr := range f.attrs {
+			if !extensionNameRegexp.MatchString(attr) {
+				errs = append(errs, field.Invalid(fldPath.Child(f.name).Key(attr), attr, "attribute name must consist of lower-case alphanumeric characters"))
+			}
+		}
+	}
+
+	if filter.All != nil {
+		dialects++
+		if len(filter.All) == 0 {
+			errs = append(errs, field.Required(fldPath.Child("all"), "at least one filter must be specified"))
+		}
+		for i := range filter.All {
+			errs = append(errs, validateEventFilter(&filter.All[i], fldPath.Child("all").Index(i))...)
+		}
+	}
+
+	if filter.Any != nil {
+		dialects++
+		if len(filter.Any) == 0 {
+			errs = append(errs, field.Required(fldPath.Child("any"), "at least one filter must be specified"))
+		}
+		for i := range filter.Any {
+			errs = append(errs, validateEventFilter(&filter.Any[i], fldPath.Child("any").Index(i))...)
+		}
+	}
+
+	if filter.Not != nil {
+		dialects++
+		errs = append(errs, validateEventFilter(filter.Not, fldPath.Child("not"))...)
+	}
+
+	if filter.SQL != "" {
+		dialects++
+		if _, err := expr.CompileSQL(filter.SQL); err != nil {
+			errs = append(errs, field.Invalid(fldPath.Child("sql"), filter.SQL, fmt.Sprintf("invalid expression: %s", err)))
+		}
+	}
+
+	switch {
+	case dialects == 0:
+		errs = append(errs, field.Required(fldPath, "one of exact, prefix, suffix, all, any, not and sql must be specified"))
+	case dialects > 1:
+		errs = append(errs, field.Forbidden(fldPath, "only one of exact, prefix, suffix, all, any, not and sql can be specified"))
+	}
+
+	return errs
+}
+
+// validateResourceTemplates validates the templates of resources.
+func validateResourceTemplates(templates []unstructured.Unstructured, fldPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	for i, tmpl := range templates {
+		if tmpl.GetAPIVersion() == "" || tmpl.GetKind() == "" {
+			errs = append(errs, field.Required(fldPath.Index(i), "apiVersion and kind must be specified"))
+		}
+
+		if err := template.ValidateObject(tmpl.UnstructuredContent()); err != nil {
+			errs = append(errs, field.Invalid(fldPath.Index(i), tmpl.GetKind(), fmt.Sprintf("invalid template: %s", err)))
+		}
+	}
+
+	return errs
+}
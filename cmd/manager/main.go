@@ -19,13 +19,17 @@ package main
 import (
 	"flag"
 	"os"
+	"strings"
+	"time"
 
 	eventreactorv1alpha1 "github.com/summerwind/eventreactor/api/v1alpha1"
 	"github.com/summerwind/eventreactor/controllers"
+	"github.com/summerwind/eventreactor/pkg/blob"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	// +kubebuilder:scaffold:imports
 )
@@ -45,9 +49,21 @@ func init() {
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
+	var enableWebhook bool
+	var dataDir string
+	var dedupeWindow time.Duration
+	var deadLetterNamespaces string
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&enableWebhook, "enable-webhook", false,
+		"Enable admission webhooks for Event, Subscription, ClusterSubscription and ResourceTemplate. Enabling this requires serving certificates, see the [WEBHOOK] sections in config/default.")
+	flag.StringVar(&dataDir, "data-dir", "",
+		"The directory of the file system data store of event payloads. It must be shared with the receiver.")
+	flag.DurationVar(&dedupeWindow, "dedupe-window", time.Hour,
+		"The duration in which events with the same source and id are rejected as duplicates by the webhook. Set 0 to disable deduplication.")
+	flag.StringVar(&deadLetterNamespaces, "dead-letter-namespaces", "",
+		"The comma separated list of namespaces into which subscriptions can copy failed events as dead letters.")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(func(o *zap.Options) {
@@ -65,10 +81,30 @@ func main() {
 		os.Exit(1)
 	}
 
+	if deadLetterNamespaces != "" {
+		eventreactorv1alpha1.DeadLetterNamespaces = strings.Split(deadLetterNamespaces, ",")
+	}
+
+	// Event payloads in ConfigMaps and Secrets are read from the API server
+	// directly to avoid caching all ConfigMaps and Secrets in the cluster.
+	dataClient := &client.DelegatingClient{
+		Reader:       mgr.GetAPIReader(),
+		Writer:       mgr.GetClient(),
+		StatusClient: mgr.GetClient(),
+	}
+	dataStores := map[string]blob.Store{
+		eventreactorv1alpha1.DataStoreConfigMap: blob.NewConfigMapStore(dataClient),
+		eventreactorv1alpha1.DataStoreSecret:    blob.NewSecretStore(dataClient),
+	}
+	if dataDir != "" {
+		dataStores[eventreactorv1alpha1.DataStoreFileSystem] = blob.NewFileSystemStore(dataDir)
+	}
+
 	if err = (&controllers.EventReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("Event"),
-		Scheme: mgr.GetScheme(),
+		Client:     mgr.GetClient(),
+		Log:        ctrl.Log.WithName("controllers").WithName("Event"),
+		Scheme:     mgr.GetScheme(),
+		DataStores: dataStores,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Event")
 		os.Exit(1)
@@ -81,6 +117,42 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "Subscription")
 		os.Exit(1)
 	}
+	if err = (&controllers.ClusterSubscriptionReconciler{
+		Client: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("controllers").WithName("ClusterSubscription"),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterSubscription")
+		os.Exit(1)
+	}
+	if err = (&controllers.EventRetentionReconciler{
+		Client:     mgr.GetClient(),
+		Log:        ctrl.Log.WithName("controllers").WithName("EventRetention"),
+		Scheme:     mgr.GetScheme(),
+		DataStores: dataStores,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "EventRetention")
+		os.Exit(1)
+	}
+	if enableWebhook {
+		eventreactorv1alpha1.DedupeWindow = dedupeWindow
+		if err = (&eventreactorv1alpha1.Event{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Event")
+			os.Exit(1)
+		}
+		if err = (&eventreactorv1alpha1.Subscription{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Subscription")
+			os.Exit(1)
+		}
+		if err = (&eventreactorv1alpha1.ClusterSubscription{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ClusterSubscription")
+			os.Exit(1)
+		}
+		if err = (&eventreactorv1alpha1.ResourceTemplate{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ResourceTemplate")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")
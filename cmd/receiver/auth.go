@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	authnv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// The interval to reload secrets for authentication.
+	secretReloadInterval = 30 * time.Second
+	// The maximum allowed age of the timestamp of Slack signature.
+	slackTimestampTolerance = 5 * time.Minute
+)
+
+var errNoCredentials = errors.New("no credentials")
+
+type principalKey struct{}
+
+// Authenticator authenticates requests.
+type Authenticator interface {
+	// Authenticate returns the principal of the request. It returns
+	// errNoCredentials if the request has no credentials for the
+	// authenticator.
+	Authenticate(r *http.Request, body []byte) (string, error)
+}
+
+// authenticate returns a handler that authenticates the request with the
+// authenticators before calling the handler. The request is allowed if any
+// of authenticators succeeds, and the authenticated principal is stored in
+// the context of request. All requests are allowed if no authenticator is
+// specified.
+func authenticate(authenticators []Authenticator, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Webhook validation handshake is allowed without credentials.
+		if len(authenticators) == 0 || r.Method == http.MethodOptions {
+			next(w, r)
+			return
+		}
+
+		reqLog := log.WithValues("remote_addr", r.RemoteAddr)
+
+		// Read the body to verify the signature and restore it for the
+		// handler.
+		body, err := readBody(r)
+		if err != nil {
+			writeError(w, reqLog, err)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		for _, authn := range authenticators {
+			principal, err := authn.Authenticate(r, body)
+			if err != nil {
+				if err != errNoCredentials {
+					reqLog.V(1).Info("Authentication failed", "error", err.Error())
+				}
+				continue
+			}
+
+			ctx := context.WithValue(r.Context(), principalKey{}, principal)
+			next(w, r.WithContext(ctx))
+			return
+		}
+
+		reqLog.Info("Unauthorized request")
+		writeProblem(w, reqLog, &Problem{Status: http.StatusUnauthorized})
+	}
+}
+
+// principalFromContext returns the authenticated principal in the context.
+func principalFromContext(ctx context.Context) string {
+	principal, _ := ctx.Value(principalKey{}).(string)
+	return principal
+}
+
+// secretStore loads the data of secret and caches it for a while.
+type secretStore struct {
+	key types.NamespacedName
+
+	mu         sync.Mutex
+	data       map[string][]byte
+	expireTime time.Time
+}
+
+func newSecretStore(namespace, name string) *secretStore {
+	return &secretStore{
+		key: types.NamespacedName{Namespace: namespace, Name: name},
+	}
+}
+
+// Get returns the data of secret.
+func (s *secretStore) Get(ctx context.Context) (map[string][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.data != nil && time.Now().Before(s.expireTime) {
+		return s.data, nil
+	}
+
+	var secret corev1.Secret
+	if err := c.Get(ctx, s.key, &secret); err != nil {
+		return nil, err
+	}
+
+	s.data = secret.Data
+	s.expireTime = time.Now().Add(secretReloadInterval)
+
+	return s.data, nil
+}
+
+// bearerToken returns the bearer token in the Authorization header.
+func bearerToken(r *http.Request) string {
+	auth := strings.TrimSpace(r.Header.Get("Authorization"))
+	parts := strings.SplitN(auth, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "bearer") {
+		return ""
+	}
+
+	return strings.TrimSpace(parts[1])
+}
+
+// tokenAuthenticator authenticates requests with static bearer tokens. Each
+// key of the secret is the name of principal and its value is the token.
+type tokenAuthenticator struct {
+	secret *secretStore
+}
+
+func (a *tokenAuthenticator) Authenticate(r *http.Request, body []byte) (string, error) {
+	token := bearerToken(r)
+	if token == "" {
+		return "", errNoCredentials
+	}
+
+	tokens, err := a.secret.Get(r.Context())
+	if err != nil {
+		return "", err
+	}
+
+	for name, t := range tokens {
+		if subtle.ConstantTimeCompare([]byte(token), bytes.TrimSpace(t)) == 1 {
+			return fmt.Sprintf("token:%s", name), nil
+		}
+	}
+
+	return "", errors.New("invalid token")
+}
+
+// HMAC signature schemes. Each scheme is enabled by the key of secret that
+// has the same name.
+const (
+	hmacSchemeGitHub  = "github"
+	hmacSchemeSlack   = "slack"
+	hmacSchemeGeneric = "generic"
+)
+
+// hmacAuthenticator authenticates requests with HMAC-SHA256 signature of
+// the request body.
+type hmacAuthenticator struct {
+	secret *secretStore
+}
+
+func (a *hmacAuthenticator) Authenticate(r *http.Request, body []byte) (string, error) {
+	var (
+		scheme    string
+		signature string
+		message   []byte
+	)
+
+	switch {
+	case r.Header.Get("X-Hub-Signature-256") != "":
+		scheme = hmacSchemeGitHub
+		signature = strings.TrimPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256=")
+		message = body
+	case r.Header.Get("X-Slack-Signature") != "":
+		ts := r.Header.Get("X-Slack-Request-Timestamp")
+		sec, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid slack request timestamp: %s", ts)
+		}
+		if d := time.Since(time.Unix(sec, 0)); d > slackTimestampTolerance || d < -slackTimestampTolerance {
+			return "", fmt.Errorf("slack request timestamp is out of range: %s", ts)
+		}
+
+		scheme = hmacSchemeSlack
+		signature = strings.TrimPrefix(r.Header.Get("X-Slack-Signature"), "v0=")
+		message = []byte(fmt.Sprintf("v0:%s:%s", ts, body))
+	case r.Header.Get("X-Signature") != "":
+		scheme = hmacSchemeGeneric
+		signature = strings.TrimPrefix(r.Header.Get("X-Signature"), "sha256=")
+		message = body
+	default:
+		return "", errNoCredentials
+	}
+
+	keys, err := a.secret.Get(r.Context())
+	if err != nil {
+		return "", err
+	}
+
+	key, ok := keys[scheme]
+	if !ok {
+		return "", fmt.Errorf("%s signature is not enabled", scheme)
+	}
+
+	if !verifySignature(bytes.TrimSpace(key), message, signature) {
+		return "", fmt.Errorf("invalid %s signature", scheme)
+	}
+
+	return fmt.Sprintf("hmac:%s", scheme), nil
+}
+
+// verifySignature verifies the hex encoded HMAC-SHA256 signature of message.
+func verifySignature(key, message []byte, signature string) bool {
+	sig, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(message)
+
+	return hmac.Equal(sig, mac.Sum(nil))
+}
+
+// clientCertAuthenticator authenticates requests with TLS client
+// certificates that are verified with the CA bundle.
+type clientCertAuthenticator struct {
+	pool *x509.CertPool
+}
+
+func (a *clientCertAuthenticator) Authenticate(r *http.Request, body []byte) (string, error) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return "", errNoCredentials
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         a.pool,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	for _, cert := range r.TLS.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+
+	cert := r.TLS.PeerCertificates[0]
+	if _, err := cert.Verify(opts); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("x509:%s", cert.Subject.CommonName), nil
+}
+
+// tokenReviewAuthenticator authenticates requests with Kubernetes
+// TokenReview API.
+type tokenReviewAuthenticator struct {
+	audiences []string
+}
+
+func (a *tokenReviewAuthenticator) Authenticate(r *http.Request, body []byte) (string, error) {
+	token := bearerToken(r)
+	if token == "" {
+		return "", errNoCredentials
+	}
+
+	tr := &authnv1.TokenReview{
+		Spec: authnv1.TokenReviewSpec{
+			Token:     token,
+			Audiences: a.audiences,
+		},
+	}
+
+	if err := c.Create(r.Context(), tr); err != nil {
+		return "", err
+	}
+
+	if !tr.Status.Authenticated {
+		if tr.Status.Error != "" {
+			return "", errors.New(tr.Status.Error)
+		}
+		return "", errors.New("token is not authenticated")
+	}
+
+	return fmt.Sprintf("kubernetes:%s", tr.Status.User.Username), nil
+}
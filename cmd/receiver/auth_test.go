@@ -0,0 +1,177 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newTestSecretStore returns a secret store that has the data without
+// loading the secret.
+func newTestSecretStore(data map[string][]byte) *secretStore {
+	return &secretStore{
+		data:       data,
+		expireTime: time.Now().Add(time.Hour),
+	}
+}
+
+func sign(key, message string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestHMACAuthenticator(t *testing.T) {
+	body := `{"action":"opened"}`
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		keys      map[string][]byte
+		headers   map[string]string
+		principal string
+		err       bool
+		noCreds   bool
+	}{
+		{
+			name:      "github",
+			keys:      map[string][]byte{"github": []byte("secret\n")},
+			headers:   map[string]string{"X-Hub-Signature-256": "sha256=" + sign("secret", body)},
+			principal: "hmac:github",
+		},
+		{
+			name:    "github with invalid signature",
+			keys:    map[string][]byte{"github": []byte("secret")},
+			headers: map[string]string{"X-Hub-Signature-256": "sha256=" + sign("other", body)},
+			err:     true,
+		},
+		{
+			name:    "github with non-hex signature",
+			keys:    map[string][]byte{"github": []byte("secret")},
+			headers: map[string]string{"X-Hub-Signature-256": "sha256=zz"},
+			err:     true,
+		},
+		{
+			name:    "github is not enabled",
+			keys:    map[string][]byte{"generic": []byte("secret")},
+			headers: map[string]string{"X-Hub-Signature-256": "sha256=" + sign("secret", body)},
+			err:     true,
+		},
+		{
+			name: "slack",
+			keys: map[string][]byte{"slack": []byte("secret")},
+			headers: map[string]string{
+				"X-Slack-Signature":         "v0=" + sign("secret", fmt.Sprintf("v0:%s:%s", now, body)),
+				"X-Slack-Request-Timestamp": now,
+			},
+			principal: "hmac:slack",
+		},
+		{
+			name: "slack signed without timestamp",
+			keys: map[string][]byte{"slack": []byte("secret")},
+			headers: map[string]string{
+				"X-Slack-Signature":         "v0=" + sign("secret", body),
+				"X-Slack-Request-Timestamp": now,
+			},
+			err: true,
+		},
+		{
+			name: "slack with stale timestamp",
+			keys: map[string][]byte{"slack": []byte("secret")},
+			headers: map[string]string{
+				"X-Slack-Signature":         "v0=" + sign("secret", fmt.Sprintf("v0:%s:%s", stale, body)),
+				"X-Slack-Request-Timestamp": stale,
+			},
+			err: true,
+		},
+		{
+			name: "slack with invalid timestamp",
+			keys: map[string][]byte{"slack": []byte("secret")},
+			headers: map[string]string{
+				"X-Slack-Signature":         "v0=" + sign("secret", body),
+				"X-Slack-Request-Timestamp": "now",
+			},
+			err: true,
+		},
+		{
+			name:      "generic",
+			keys:      map[string][]byte{"generic": []byte("secret")},
+			headers:   map[string]string{"X-Signature": sign("secret", body)},
+			principal: "hmac:generic",
+		},
+		{
+			name:      "generic with prefix",
+			keys:      map[string][]byte{"generic": []byte("secret")},
+			headers:   map[string]string{"X-Signature": "sha256=" + sign("secret", body)},
+			principal: "hmac:generic",
+		},
+		{
+			name:    "no signature",
+			keys:    map[string][]byte{"generic": []byte("secret")},
+			err:     true,
+			noCreds: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			authn := &hmacAuthenticator{secret: newTestSecretStore(tt.keys)}
+			principal, err := authn.Authenticate(req, []byte(body))
+			if (err != nil) != tt.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.noCreds && err != errNoCredentials {
+				t.Errorf("error = %v, want %v", err, errNoCredentials)
+			}
+			if principal != tt.principal {
+				t.Errorf("principal = %q, want %q", principal, tt.principal)
+			}
+		})
+	}
+}
+
+func TestTokenAuthenticator(t *testing.T) {
+	tokens := map[string][]byte{"ci": []byte("token\n")}
+
+	tests := []struct {
+		name      string
+		auth      string
+		principal string
+		err       bool
+	}{
+		{"valid token", "Bearer token", "token:ci", false},
+		{"case-insensitive scheme", "bearer  token ", "token:ci", false},
+		{"invalid token", "Bearer other", "", true},
+		{"basic auth", "Basic dXNlcjpwYXNz", "", true},
+		{"no header", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+
+			authn := &tokenAuthenticator{secret: newTestSecretStore(tokens)}
+			principal, err := authn.Authenticate(req, nil)
+			if (err != nil) != tt.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if principal != tt.principal {
+				t.Errorf("principal = %q, want %q", principal, tt.principal)
+			}
+		})
+	}
+}
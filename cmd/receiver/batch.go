@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-logr/logr"
+)
+
+// maxBatchEntries is the maximum number of events in a batch, since each
+// event is created sequentially in the request.
+const maxBatchEntries = 100
+
+// BatchResult represents the result of an event in batch.
+type BatchResult struct {
+	// ID is the ID of event.
+	ID string `json:"id,omitempty"`
+	// Source is the source of event.
+	Source string `json:"source,omitempty"`
+	// Name is the name of created event resource.
+	Name string `json:"name,omitempty"`
+	// Status is the HTTP status code of the event.
+	Status int `json:"status"`
+	// Error is the error message if the event could not be accepted.
+	Error string `json:"error,omitempty"`
+}
+
+// batchHandler handles the request in batched content mode. Each event in
+// the batch is processed independently and the result of each event is
+// returned in the response body.
+func batchHandler(w http.ResponseWriter, r *http.Request, reqLog logr.Logger) {
+	body, err := readBody(r)
+	if err != nil {
+		writeError(w, reqLog, err)
+		return
+	}
+
+	entries := []json.RawMessage{}
+	if err := json.Unmarshal(body, &entries); err != nil {
+		writeError(w, reqLog, badRequest("", "invalid batch format: %s", err))
+		return
+	}
+	if len(entries) > maxBatchEntries {
+		writeError(w, reqLog, &requestError{
+			status:  http.StatusRequestEntityTooLarge,
+			message: fmt.Sprintf("batch exceeds %d events", maxBatchEntries),
+		})
+		return
+	}
+
+	results := make([]BatchResult, len(entries))
+	failed := 0
+
+	for i, entry := range entries {
+		ce := &CloudEvent{}
+		if err := json.Unmarshal(entry, ce); err != nil {
+			results[i] = BatchResult{Status: http.StatusBadRequest, Error: err.Error()}
+			failed++
+			continue
+		}
+
+		results[i].ID = ce.ID
+		results[i].Source = ce.Source
+
+		created := false
+		ev, err := newEvent(ce)
+		if err == nil {
+			created, err = createEvent(r.Context(), ev)
+		}
+		if err != nil {
+			results[i].Status, _ = errorStatus(err)
+			results[i].Error = err.Error()
+			if results[i].Status >= http.StatusInternalServerError {
+				// The event is nil if it could not be parsed, so log the
+				// requested namespace.
+				reqLog.Error(err, "Failed to create event resource", "namespace", namespaceFromContext(r.Context()))
+				results[i].Error = "failed to create event resource"
+			}
+			failed++
+			continue
+		}
+
+		results[i].Name = ev.Name
+		if !created {
+			reqLog.Info("Duplicate event", "name", ev.Name, "namespace", ev.Namespace)
+			results[i].Status = http.StatusOK
+			continue
+		}
+
+		reqLog.Info("Event resource created", "name", ev.Name, "namespace", ev.Namespace)
+		results[i].Status = http.StatusAccepted
+	}
+
+	// Use 207 Multi-Status to indicate that some events in the batch were
+	// not accepted. The result of each event is in the response body.
+	status := http.StatusOK
+	if failed > 0 {
+		status = http.StatusMultiStatus
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		reqLog.Error(err, "Failed to write response")
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	crlog "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestBatchHandlerMaxEntries(t *testing.T) {
+	defer func(size int64) {
+		maxBodySize = size
+	}(maxBodySize)
+	maxBodySize = 1024 * 1024
+
+	entries := make([]string, maxBatchEntries+1)
+	for i := range entries {
+		entries[i] = "{}"
+	}
+	body := "[" + strings.Join(entries, ",") + "]"
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1alpha1/events", strings.NewReader(body))
+	req.Header.Set("Content-Type", batchContentType)
+	rec := httptest.NewRecorder()
+
+	batchHandler(rec, req, crlog.NullLogger{})
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("got status %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+}
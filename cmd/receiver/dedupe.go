@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/summerwind/eventreactor/api/v1alpha1"
+)
+
+// dedupeName returns the name of the event derived from its source and ID.
+// Concurrent deliveries of the same event have the same name, so that only
+// one of them is created by the API server.
+func dedupeName(ev *v1alpha1.Event) string {
+	return v1alpha1.DedupeKey(ev.Spec.Source, ev.Spec.ID)
+}
+
+// getEvent returns the event of the name. It returns nil if the event does
+// not exist.
+func getEvent(ctx context.Context, namespace, name string) (*v1alpha1.Event, error) {
+	var ev v1alpha1.Event
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &ev); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+
+	return &ev, nil
+}
+
+// isDuplicate returns true if the existing event has the same source and
+// ID as the event and was created within the dedupe window.
+func isDuplicate(existing, ev *v1alpha1.Event) bool {
+	// Names may be set by users, so compare the attributes.
+	if existing.Spec.Source != ev.Spec.Source || existing.Spec.ID != ev.Spec.ID {
+		return false
+	}
+
+	return !existing.CreationTimestamp.Time.Before(time.Now().Add(-dedupeWindow))
+}
+
+// findDuplicate returns the event that has the name of the event and is a
+// duplicate of it. It returns nil if deduplication is disabled or there is
+// no such event.
+func findDuplicate(ctx context.Context, ev *v1alpha1.Event) (*v1alpha1.Event, error) {
+	if dedupeWindow <= 0 {
+		return nil, nil
+	}
+
+	existing, err := getEvent(ctx, ev.Namespace, ev.Name)
+	if err != nil || existing == nil || !isDuplicate(existing, ev) {
+		return nil, err
+	}
+
+	return existing, nil
+}
+
+// errDuplicateInProgress is returned if the same event is being created by
+// another request.
+var errDuplicateInProgress = &requestError{
+	status:  http.StatusConflict,
+	message: "the same event is being created",
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/summerwind/eventreactor/api/v1alpha1"
+)
+
+func TestCreateEventDedupe(t *testing.T) {
+	defer func(cl client.Client, r *namespaceRouter, window time.Duration) {
+		c, router, dedupeWindow = cl, r, window
+	}(c, router, dedupeWindow)
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	newEvent := func(source, id string) *v1alpha1.Event {
+		return &v1alpha1.Event{
+			Spec: v1alpha1.EventSpec{Source: source, ID: id, Type: "test"},
+		}
+	}
+	existing := func(source, id string, age time.Duration) runtime.Object {
+		ev := newEvent(source, id)
+		ev.ObjectMeta = metav1.ObjectMeta{
+			Namespace:         "default",
+			Name:              dedupeName(ev),
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-age)),
+		}
+		return ev
+	}
+
+	tests := []struct {
+		name    string
+		window  time.Duration
+		objs    []runtime.Object
+		created bool
+		dedupe  bool
+	}{
+		{
+			name:    "new event",
+			window:  time.Hour,
+			created: true,
+			dedupe:  true,
+		},
+		{
+			name:    "duplicate",
+			window:  time.Hour,
+			objs:    []runtime.Object{existing("/test", "1", time.Minute)},
+			created: false,
+			dedupe:  true,
+		},
+		{
+			name:    "out of window",
+			window:  time.Hour,
+			objs:    []runtime.Object{existing("/test", "1", 2*time.Hour)},
+			created: true,
+		},
+		{
+			name:    "disabled",
+			objs:    []runtime.Object{existing("/test", "1", time.Minute)},
+			created: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c = fake.NewFakeClientWithScheme(scheme, tt.objs...)
+			router = newNamespaceRouter("default", nil)
+			dedupeWindow = tt.window
+
+			ev := newEvent("/test", "1")
+			created, err := createEvent(context.Background(), ev)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if created != tt.created {
+				t.Errorf("got created %v, want %v", created, tt.created)
+			}
+			if (ev.Name == dedupeName(ev)) != tt.dedupe {
+				t.Errorf("got name %s, want dedupe name %v", ev.Name, tt.dedupe)
+			}
+
+			var got v1alpha1.Event
+			if err := c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: ev.Name}, &got); err != nil {
+				t.Errorf("failed to get event: %s", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/summerwind/eventreactor/api/v1alpha1"
+)
+
+const (
+	// The prefix of the type of events from GitHub webhook.
+	githubEventTypePrefix = "com.github."
+	// The key of the secret that contains the webhook secret of GitHub.
+	githubSecretKey = "secret"
+)
+
+// githubPayload is the set of fields in GitHub webhook payload that are
+// used to build CloudEvents attributes.
+type githubPayload struct {
+	Ref        string `json:"ref"`
+	Repository *struct {
+		FullName string `json:"full_name"`
+		HTMLURL  string `json:"html_url"`
+	} `json:"repository"`
+	Organization *struct {
+		Login string `json:"login"`
+	} `json:"organization"`
+	PullRequest *struct {
+		Number int `json:"number"`
+	} `json:"pull_request"`
+	Issue *struct {
+		Number int `json:"number"`
+	} `json:"issue"`
+	CheckSuite *struct {
+		HeadSHA string `json:"head_sha"`
+	} `json:"check_suite"`
+	CheckRun *struct {
+		HeadSHA string `json:"head_sha"`
+	} `json:"check_run"`
+}
+
+// githubAdapter converts GitHub webhook requests into events.
+type githubAdapter struct {
+	// secret is the store of webhook secret to verify the signature of
+	// requests.
+	secret *secretStore
+}
+
+func (a *githubAdapter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reqLog := log.WithValues("remote_addr", r.RemoteAddr, "adapter", "github")
+
+	if r.Method == http.MethodOptions {
+		handleOptions(w, reqLog, r)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, reqLog, r)
+		return
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		writeError(w, reqLog, err)
+		return
+	}
+
+	if err := a.verify(r, body); err != nil {
+		reqLog.Info("Unauthorized request", "error", err.Error())
+		writeProblem(w, reqLog, &Problem{Status: http.StatusUnauthorized})
+		return
+	}
+
+	ev, err := newGitHubEvent(r.Header, body)
+	if err != nil {
+		writeError(w, reqLog, err)
+		return
+	}
+
+	created, err := createEvent(r.Context(), ev)
+	if err != nil {
+		writeError(w, reqLog.WithValues("namespace", ev.Namespace), err)
+		return
+	}
+	if !created {
+		reqLog.Info("Duplicate event", "name", ev.Name, "namespace", ev.Namespace)
+		writeEvent(w, reqLog, ev, http.StatusOK)
+		return
+	}
+
+	reqLog.Info("Event resource created", "name", ev.Name, "namespace", ev.Namespace, "type", ev.Spec.Type)
+	writeEvent(w, reqLog, ev, http.StatusAccepted)
+}
+
+// verify verifies the signature of the request with the webhook secret.
+func (a *githubAdapter) verify(r *http.Request, body []byte) error {
+	if a.secret == nil {
+		return errors.New("webhook secret is not configured")
+	}
+
+	signature := r.Header.Get("X-Hub-Signature-256")
+	if signature == "" {
+		return errors.New("signature header not found")
+	}
+
+	data, err := a.secret.Get(r.Context())
+	if err != nil {
+		return err
+	}
+
+	key, ok := data[githubSecretKey]
+	if !ok {
+		return fmt.Errorf("webhook secret not found in key '%s'", githubSecretKey)
+	}
+
+	if !verifySignature(bytes.TrimSpace(key), body, strings.TrimPrefix(signature, "sha256=")) {
+		return errors.New("invalid signature")
+	}
+
+	return nil
+}
+
+// newGitHubEvent returns a new event from the headers and the body of
+// GitHub webhook request. The type of event is the name of GitHub event
+// with 'com.github.' prefix, and the ID is the delivery ID.
+func newGitHubEvent(header http.Header, body []byte) (*v1alpha1.Event, error) {
+	eventName := header.Get("X-GitHub-Event")
+	if eventName == "" {
+		return nil, badRequest("type", "X-GitHub-Event header must be specified")
+	}
+
+	deliveryID := header.Get("X-GitHub-Delivery")
+	if deliveryID == "" {
+		return nil, badRequest("id", "X-GitHub-Delivery header must be specified")
+	}
+
+	// GitHub sends the payload in 'payload' parameter if the content type
+	// of webhook is 'application/x-www-form-urlencoded'.
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	if mediaType == "application/x-www-form-urlencoded" {
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, badRequest("data", "invalid form data: %s", err)
+		}
+		body = []byte(values.Get("payload"))
+	}
+
+	payload := githubPayload{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, badRequest("data", "invalid payload: %s", err)
+	}
+
+	now := metav1.NewTime(time.Now())
+
+	ev := v1alpha1.Event{}
+	ev.Spec = v1alpha1.EventSpec{
+		ID:              deliveryID,
+		Source:          githubSource(&payload),
+		Type:            githubEventTypePrefix + eventName,
+		DataContentType: "application/json",
+		Subject:         githubSubject(&payload),
+		Time:            &now,
+	}
+	setEventData(&ev, ev.Spec.DataContentType, body)
+
+	return &ev, nil
+}
+
+// githubSource returns the URL of the repository or the organization that
+// the event belongs to.
+func githubSource(payload *githubPayload) string {
+	if payload.Repository != nil {
+		if payload.Repository.HTMLURL != "" {
+			return payload.Repository.HTMLURL
+		}
+		if payload.Repository.FullName != "" {
+			return "https://github.com/" + payload.Repository.FullName
+		}
+	}
+
+	if payload.Organization != nil && payload.Organization.Login != "" {
+		return "https://github.com/" + payload.Organization.Login
+	}
+
+	return "https://github.com"
+}
+
+// githubSubject returns the subject of the event in the repository such as
+// the git ref or the number of pull request.
+func githubSubject(payload *githubPayload) string {
+	switch {
+	case payload.PullRequest != nil:
+		return fmt.Sprintf("%d", payload.PullRequest.Number)
+	case payload.Issue != nil:
+		return fmt.Sprintf("%d", payload.Issue.Number)
+	case payload.CheckSuite != nil:
+		return payload.CheckSuite.HeadSHA
+	case payload.CheckRun != nil:
+		return payload.CheckRun.HeadSHA
+	case payload.Ref != "":
+		return payload.Ref
+	}
+
+	return ""
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGitHubAdapterVerify(t *testing.T) {
+	body := `{"ref":"refs/heads/main"}`
+	secret := newTestSecretStore(map[string][]byte{githubSecretKey: []byte("secret")})
+
+	tests := []struct {
+		name      string
+		secret    *secretStore
+		signature string
+		err       bool
+	}{
+		{"valid signature", secret, "sha256=" + sign("secret", body), false},
+		{"invalid signature", secret, "sha256=" + sign("other", body), true},
+		{"no signature", secret, "", true},
+		{"no secret", nil, "sha256=" + sign("secret", body), true},
+		{"no secret key", newTestSecretStore(map[string][]byte{}), "sha256=" + sign("secret", body), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/adapters/github", nil)
+			if tt.signature != "" {
+				req.Header.Set("X-Hub-Signature-256", tt.signature)
+			}
+
+			a := &githubAdapter{secret: tt.secret}
+			err := a.verify(req, []byte(body))
+			if (err != nil) != tt.err {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestNewGitHubEvent(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		data        string
+		dataBase64  string
+		subject     string
+		err         bool
+	}{
+		{
+			name:        "json",
+			contentType: "application/json",
+			body:        `{"ref":"refs/heads/main"}`,
+			data:        `{"ref":"refs/heads/main"}`,
+			subject:     "refs/heads/main",
+		},
+		{
+			name:        "form",
+			contentType: "application/x-www-form-urlencoded",
+			body:        `payload=%7B%22pull_request%22%3A%7B%22number%22%3A1%7D%7D`,
+			data:        `{"pull_request":{"number":1}}`,
+			subject:     "1",
+		},
+		{
+			name:        "invalid utf-8",
+			contentType: "application/json",
+			body:        "{\"ref\":\"\xff\"}",
+			dataBase64:  base64.StdEncoding.EncodeToString([]byte("{\"ref\":\"\xff\"}")),
+			subject:     "�",
+		},
+		{
+			name:        "invalid json",
+			contentType: "application/json",
+			body:        `{`,
+			err:         true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set("Content-Type", tt.contentType)
+			header.Set("X-GitHub-Event", "push")
+			header.Set("X-GitHub-Delivery", "1")
+
+			ev, err := newGitHubEvent(header, []byte(tt.body))
+			if (err != nil) != tt.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.err {
+				return
+			}
+
+			if ev.Spec.Type != "com.github.push" {
+				t.Errorf("type = %q", ev.Spec.Type)
+			}
+			if ev.Spec.Data != tt.data {
+				t.Errorf("data = %q, want %q", ev.Spec.Data, tt.data)
+			}
+			if ev.Spec.DataBase64 != tt.dataBase64 {
+				t.Errorf("data_base64 = %q, want %q", ev.Spec.DataBase64, tt.dataBase64)
+			}
+			if ev.Spec.Subject != tt.subject {
+				t.Errorf("subject = %q, want %q", ev.Spec.Subject, tt.subject)
+			}
+		})
+	}
+}
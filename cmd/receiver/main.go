@@ -2,19 +2,25 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"mime"
 	"net/http"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
 	"time"
+	"unicode/utf8"
 
 	"github.com/go-logr/logr"
 	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
@@ -25,6 +31,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	"github.com/summerwind/eventreactor/api/v1alpha1"
+	"github.com/summerwind/eventreactor/pkg/blob"
 )
 
 var (
@@ -34,19 +41,81 @@ var (
 	certFile  string
 	keyFile   string
 
-	c   client.Client
-	log logr.Logger
+	authTokenSecret          string
+	authHMACSecret           string
+	authClientCAFile         string
+	authTokenReview          bool
+	authTokenReviewAudiences []string
+
+	githubSecret string
+	mappingFile  string
+
+	routingFile     string
+	namespaceHeader string
+
+	allowedOrigins []string
+
+	dedupeWindow time.Duration
+
+	rateLimitFile string
+
+	maxBodySize      int64
+	offloadThreshold int64
+	offloadStore     string
+	offloadDir       string
+
+	c         client.Client
+	router    *namespaceRouter
+	dataStore blob.Store
+	limiter   *rateLimiter
+	log       logr.Logger
 )
 
-var allowedContentType = []string{
+// textContentType is the list of content type prefixes that are stored as
+// text. Other payloads are stored as base64 encoded data.
+var textContentType = []string{
 	"text/",
 	"application/json",
 	"application/xml",
 	"application/x-www-form-urlencoded",
-	"application/cloudevents+json",
 }
 
-const supportedSpecVersion = "1.0"
+const (
+	specVersion10 = v1alpha1.SpecVersion10
+	specVersion03 = v1alpha1.SpecVersion03
+
+	structuredContentType = "application/cloudevents+json"
+	batchContentType      = "application/cloudevents-batch+json"
+)
+
+// contextAttributes is the set of context attributes of each spec version.
+// Other attributes are treated as extension attributes.
+var contextAttributes = map[string]map[string]bool{
+	specVersion10: {
+		"specversion":     true,
+		"id":              true,
+		"source":          true,
+		"type":            true,
+		"datacontenttype": true,
+		"dataschema":      true,
+		"subject":         true,
+		"time":            true,
+		"data":            true,
+		"data_base64":     true,
+	},
+	specVersion03: {
+		"specversion":         true,
+		"id":                  true,
+		"source":              true,
+		"type":                true,
+		"datacontenttype":     true,
+		"datacontentencoding": true,
+		"schemaurl":           true,
+		"subject":             true,
+		"time":                true,
+		"data":                true,
+	},
+}
 
 type CloudEvent struct {
 	SpecVersion     string          `json:"specversion"`
@@ -58,113 +127,418 @@ type CloudEvent struct {
 	Subject         string          `json:"subject"`
 	Time            string          `json:"time"`
 	Data            json.RawMessage `json:"data"`
+	DataBase64      string          `json:"data_base64"`
+
+	// Attributes of spec 0.3.
+	SchemaURL           string `json:"schemaurl"`
+	DataContentEncoding string `json:"datacontentencoding"`
+
+	// Extensions is the extension attributes.
+	Extensions map[string]string `json:"-"`
+}
+
+// UnmarshalJSON decodes the CloudEvent in JSON format. Attributes that are
+// not defined in its spec version are stored as extension attributes.
+func (ce *CloudEvent) UnmarshalJSON(b []byte) error {
+	type cloudEvent CloudEvent
+	if err := json.Unmarshal(b, (*cloudEvent)(ce)); err != nil {
+		return err
+	}
+
+	attrs := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &attrs); err != nil {
+		return err
+	}
+
+	known, ok := contextAttributes[ce.SpecVersion]
+	if !ok {
+		return nil
+	}
+
+	for name, raw := range attrs {
+		if known[name] {
+			continue
+		}
+
+		// Extension attributes are strings in general, but other types
+		// such as integer and boolean are stored in JSON representation.
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			value = string(raw)
+		}
+
+		if ce.Extensions == nil {
+			ce.Extensions = map[string]string{}
+		}
+		ce.Extensions[name] = value
+	}
+
+	return nil
 }
 
 func parseRequest(r *http.Request) (*v1alpha1.Event, error) {
 	contentType := r.Header.Get("Content-Type")
 	if contentType == "" {
-		return nil, errors.New("content-type header must be specified")
+		return nil, badRequest("datacontenttype", "content-type header must be specified")
 	}
 
-	allowed := false
-	for _, prefix := range allowedContentType {
-		if strings.HasPrefix(contentType, prefix) {
-			allowed = true
-			break
+	body, err := readBody(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(contentType, structuredContentType) {
+		ce := &CloudEvent{}
+		if err := json.Unmarshal(body, ce); err != nil {
+			return nil, badRequest("", "invalid event format: %s", err)
 		}
+
+		return newEvent(ce)
 	}
-	if !allowed {
-		return nil, fmt.Errorf("unsupported content-type: %s", contentType)
+
+	// Only JSON format is supported in structured mode.
+	if strings.HasPrefix(contentType, "application/cloudevents") {
+		return nil, unsupportedMediaType(contentType)
 	}
 
-	body, err := ioutil.ReadAll(r.Body)
-	if err != nil {
+	specVersion := r.Header.Get("ce-specversion")
+	known, ok := contextAttributes[specVersion]
+	if !ok {
+		return nil, badRequest("specversion", "unsupported specversion: %s", specVersion)
+	}
+
+	ev := v1alpha1.Event{}
+	ev.Spec = v1alpha1.EventSpec{
+		SpecVersion:     specVersion,
+		ID:              r.Header.Get("ce-id"),
+		Source:          r.Header.Get("ce-source"),
+		Type:            r.Header.Get("ce-type"),
+		DataContentType: r.Header.Get("content-type"),
+		DataSchema:      r.Header.Get("ce-dataschema"),
+		Subject:         r.Header.Get("ce-subject"),
+	}
+
+	setEventData(&ev, contentType, body)
+
+	if specVersion == specVersion03 {
+		ev.Spec.DataSchema = r.Header.Get("ce-schemaurl")
+	}
+
+	for key := range r.Header {
+		name := strings.ToLower(key)
+		if !strings.HasPrefix(name, "ce-") {
+			continue
+		}
+
+		name = strings.TrimPrefix(name, "ce-")
+		if known[name] {
+			continue
+		}
+
+		if ev.Spec.Extensions == nil {
+			ev.Spec.Extensions = map[string]string{}
+		}
+		ev.Spec.Extensions[name] = r.Header.Get(key)
+	}
+
+	if err := setEventTime(&ev, r.Header.Get("ce-time")); err != nil {
+		return nil, err
+	}
+
+	if err := validateAttributes(&ev); err != nil {
 		return nil, err
 	}
 
+	return &ev, nil
+}
+
+// newEvent returns a new event from the CloudEvent in structured mode.
+func newEvent(ce *CloudEvent) (*v1alpha1.Event, error) {
+	if _, ok := contextAttributes[ce.SpecVersion]; !ok {
+		return nil, badRequest("specversion", "unsupported specversion: %s", ce.SpecVersion)
+	}
+
 	ev := v1alpha1.Event{}
-	if contentType == "application/cloudevents+json" {
-		ce := &CloudEvent{}
-		if err := json.Unmarshal(body, ce); err != nil {
-			return nil, err
+	ev.Spec = v1alpha1.EventSpec{
+		SpecVersion:     ce.SpecVersion,
+		ID:              ce.ID,
+		Source:          ce.Source,
+		Type:            ce.Type,
+		DataContentType: ce.DataContentType,
+		DataSchema:      ce.DataSchema,
+		Subject:         ce.Subject,
+		Extensions:      ce.Extensions,
+		Data:            string(ce.Data),
+	}
+
+	if ce.SpecVersion == specVersion03 {
+		ev.Spec.DataSchema = ce.SchemaURL
+
+		switch ce.DataContentEncoding {
+		case "":
+		case "base64":
+			// The data is a JSON string that contains the base64 encoded
+			// data.
+			var encoded string
+			if err := json.Unmarshal(ce.Data, &encoded); err != nil {
+				return nil, badRequest("data", "data must be a base64 encoded string")
+			}
+			ev.Spec.Data = ""
+			ev.Spec.DataBase64 = encoded
+		default:
+			return nil, badRequest("datacontentencoding", "unsupported datacontentencoding: %s", ce.DataContentEncoding)
 		}
+	}
 
-		if ce.SpecVersion != supportedSpecVersion {
-			return nil, fmt.Errorf("unsupported specversion: %s", ce.SpecVersion)
+	if ce.SpecVersion == specVersion10 && ce.DataBase64 != "" {
+		if len(ce.Data) > 0 {
+			return nil, badRequest("data_base64", "data and data_base64 cannot be specified at the same time")
 		}
+		ev.Spec.DataBase64 = ce.DataBase64
+	}
 
-		ev.Spec = v1alpha1.EventSpec{
-			ID:              ce.ID,
-			Source:          ce.Source,
-			Type:            ce.Type,
-			DataContentType: ce.DataContentType,
-			DataSchema:      ce.DataSchema,
-			Subject:         ce.Subject,
-			Data:            string(ce.Data),
+	if ev.Spec.DataBase64 != "" {
+		if _, err := base64.StdEncoding.DecodeString(ev.Spec.DataBase64); err != nil {
+			return nil, badRequest("data", "invalid base64 data: %s", err)
 		}
+	}
+
+	if err := setEventTime(&ev, ce.Time); err != nil {
+		return nil, err
+	}
+
+	if err := validateAttributes(&ev); err != nil {
+		return nil, err
+	}
+
+	return &ev, nil
+}
 
-		if ce.Time != "" {
-			cet, err := time.Parse(time.RFC3339, ce.Time)
-			if err == nil {
-				t := metav1.NewTime(cet)
-				ev.Spec.Time = &t
+// isText returns true if the content type is textual.
+func isText(contentType string) bool {
+	for _, prefix := range textContentType {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml")
+}
+
+// setEventData sets the body of request to the data of event. The body is
+// encoded with base64 unless it is a valid UTF-8 text.
+func setEventData(ev *v1alpha1.Event, contentType string, body []byte) {
+	if isText(contentType) && utf8.Valid(body) {
+		ev.Spec.Data = string(body)
+	} else if len(body) > 0 {
+		ev.Spec.DataBase64 = base64.StdEncoding.EncodeToString(body)
+	}
+}
+
+// setEventTime sets the time of event from the value of time attribute.
+func setEventTime(ev *v1alpha1.Event, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	cet, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return badRequest("time", "invalid time: %s", value)
+	}
+
+	t := metav1.NewTime(cet)
+	ev.Spec.Time = &t
+
+	return nil
+}
+
+// validateAttributes validates that the required attributes are specified.
+func validateAttributes(ev *v1alpha1.Event) error {
+	if ev.Spec.ID == "" {
+		return badRequest("id", "id must be specified")
+	}
+	if ev.Spec.Source == "" {
+		return badRequest("source", "source must be specified")
+	}
+	if ev.Spec.Type == "" {
+		return badRequest("type", "type must be specified")
+	}
+
+	return nil
+}
+
+// createEvent creates the event resource in the namespace decided by the
+// router. The authenticated principal in the context is recorded as an
+// annotation. If deduplication is enabled, the name of event is derived
+// from its source and ID, and if the event with the same source and ID
+// already exists, it returns false and the metadata of the event is set to
+// the existing one.
+func createEvent(ctx context.Context, ev *v1alpha1.Event) (bool, error) {
+	ns, err := router.Route(ctx, ev)
+	if err != nil {
+		return false, err
+	}
+
+	ev.ObjectMeta = metav1.ObjectMeta{
+		Namespace: ns,
+		Name:      v1alpha1.NewEventName(),
+		Labels: map[string]string{
+			v1alpha1.DedupeKeyLabel: v1alpha1.DedupeKey(ev.Spec.Source, ev.Spec.ID),
+		},
+	}
+	ev.Status.Phase = v1alpha1.EventPhasePending
+
+	if principal := principalFromContext(ctx); principal != "" {
+		ev.Annotations = map[string]string{
+			v1alpha1.PrincipalAnnotation: principal,
+		}
+	}
+
+	if err := limiter.Allow(ctx, ev); err != nil {
+		return false, err
+	}
+
+	if dedupeWindow > 0 {
+		ev.Name = dedupeName(ev)
+
+		existing, err := getEvent(ctx, ev.Namespace, ev.Name)
+		if err != nil {
+			return false, err
+		}
+		if existing != nil {
+			if isDuplicate(existing, ev) {
+				ev.ObjectMeta = existing.ObjectMeta
+				return false, nil
 			}
+			// The name is used by the event out of the dedupe window.
+			ev.Name = v1alpha1.NewEventName()
 		}
-	} else {
-		specVersion := r.Header.Get("ce-specversion")
-		if specVersion != supportedSpecVersion {
-			return nil, fmt.Errorf("unsupported specversion: %s", specVersion)
+	}
+
+	offloaded, err := offloadData(ctx, ev)
+	if err != nil {
+		// The data of the same event has been stored by the concurrent
+		// request that has not created the event yet.
+		if dedupeWindow > 0 && apierrors.IsAlreadyExists(err) {
+			return false, errDuplicateInProgress
 		}
+		return false, err
+	}
 
-		ev.Spec = v1alpha1.EventSpec{
-			ID:              r.Header.Get("ce-id"),
-			Source:          r.Header.Get("ce-source"),
-			Type:            r.Header.Get("ce-type"),
-			DataContentType: r.Header.Get("content-type"),
-			DataSchema:      r.Header.Get("ce-dataschema"),
-			Subject:         r.Header.Get("ce-subject"),
-			Data:            string(body),
+	if err := c.Create(ctx, ev); err != nil {
+		// The same event has been created concurrently. Its payload has
+		// the same name, so it must not be deleted.
+		if dup, dupErr := findDuplicate(ctx, ev); dupErr == nil && dup != nil {
+			ev.ObjectMeta = dup.ObjectMeta
+			return false, nil
 		}
 
-		if r.Header.Get("ce-time") != "" {
-			cet, err := time.Parse(time.RFC3339, r.Header.Get("ce-time"))
-			if err == nil {
-				t := metav1.NewTime(cet)
-				ev.Spec.Time = &t
+		if offloaded {
+			if err := dataStore.Delete(ctx, ev.Namespace, ev.Name); err != nil {
+				log.Error(err, "Failed to delete event data", "name", ev.Name, "namespace", ev.Namespace)
 			}
 		}
+
+		return false, err
 	}
 
-	return &ev, nil
+	if offloaded {
+		// The event has been created, so failing to set the owner
+		// reference only leaves the data until it is deleted manually.
+		if err := adoptData(ctx, ev); err != nil {
+			log.Error(err, "Failed to set owner reference to event data", "name", ev.Name, "namespace", ev.Namespace)
+		}
+	}
+
+	return true, nil
 }
 
 func eventHandler(w http.ResponseWriter, r *http.Request) {
 	reqLog := log.WithValues("remote_addr", r.RemoteAddr)
 
+	if r.Method == http.MethodOptions {
+		handleOptions(w, reqLog, r)
+		return
+	}
+
 	if r.Method != http.MethodPost {
-		reqLog.V(1).Info("Invalid request method", "method", r.Method)
-		http.Error(w, "Not Implemented", http.StatusNotImplemented)
+		methodNotAllowed(w, reqLog, r)
+		return
+	}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), batchContentType) {
+		batchHandler(w, r, reqLog)
 		return
 	}
 
 	ev, err := parseRequest(r)
 	if err != nil {
-		reqLog.Error(err, "Invalid request")
-		http.Error(w, "Bad Request", http.StatusBadRequest)
+		writeError(w, reqLog, err)
+		return
 	}
 
-	ev.ObjectMeta = metav1.ObjectMeta{
-		Namespace: namespace,
-		Name:      v1alpha1.NewEventName(),
+	created, err := createEvent(r.Context(), ev)
+	if err != nil {
+		writeError(w, reqLog.WithValues("namespace", ev.Namespace), err)
+		return
 	}
-
-	if err := c.Create(context.Background(), ev); err != nil {
-		reqLog.Error(err, "Failed to create event resource", "name", ev.Name, "namespace", ev.Namespace)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	if !created {
+		reqLog.Info("Duplicate event", "name", ev.Name, "namespace", ev.Namespace)
+		writeEvent(w, reqLog, ev, http.StatusOK)
 		return
 	}
 
 	reqLog.Info("Event resource created", "name", ev.Name, "namespace", ev.Namespace)
+	writeEvent(w, reqLog, ev, http.StatusAccepted)
+}
+
+// newAuthenticators returns the list of authenticators that are enabled
+// by the flags.
+func newAuthenticators() ([]Authenticator, error) {
+	authenticators := []Authenticator{}
+
+	if authTokenSecret != "" {
+		authenticators = append(authenticators, &tokenAuthenticator{
+			secret: newSecretStore(namespace, authTokenSecret),
+		})
+	}
+
+	if authHMACSecret != "" {
+		authenticators = append(authenticators, &hmacAuthenticator{
+			secret: newSecretStore(namespace, authHMACSecret),
+		})
+	}
+
+	if authClientCAFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, errors.New("--auth-client-ca-file requires --tls-cert-file and --tls-private-key-file")
+		}
+
+		ca, err := ioutil.ReadFile(authClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no valid certificate found in %s", authClientCAFile)
+		}
+
+		authenticators = append(authenticators, &clientCertAuthenticator{pool: pool})
+	}
+
+	if authTokenReview {
+		authenticators = append(authenticators, &tokenReviewAuthenticator{
+			audiences: authTokenReviewAudiences,
+		})
+	}
+
+	return authenticators, nil
 }
 
 func run(cmd *cobra.Command, args []string) error {
@@ -188,12 +562,98 @@ func run(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	authenticators, err := newAuthenticators()
+	if err != nil {
+		return err
+	}
+
+	var routing *RoutingConfig
+	if routingFile != "" {
+		routing, err = loadRoutingConfig(routingFile)
+		if err != nil {
+			return err
+		}
+	}
+	router = newNamespaceRouter(namespace, routing)
+
+	switch offloadStore {
+	case "":
+	case v1alpha1.DataStoreConfigMap:
+		dataStore = blob.NewConfigMapStore(c)
+	case v1alpha1.DataStoreSecret:
+		dataStore = blob.NewSecretStore(c)
+	case v1alpha1.DataStoreFileSystem:
+		if offloadDir == "" {
+			return errors.New("--offload-dir must be specified with FileSystem store")
+		}
+		dataStore = blob.NewFileSystemStore(offloadDir)
+	default:
+		return fmt.Errorf("invalid data store: %s", offloadStore)
+	}
+
 	mux := http.NewServeMux()
-	mux.HandleFunc("/api/v1alpha1/events", eventHandler)
+	mux.HandleFunc("/api/v1alpha1/events", authenticate(authenticators, eventHandler))
+	mux.HandleFunc(namespacedEventsPathPrefix, namespacedEventHandler(authenticate(authenticators, eventHandler)))
+
+	// GitHub adapter verifies the signature of requests with its own
+	// webhook secret instead of the authenticators, so it is enabled only
+	// if the webhook secret is specified.
+	if githubSecret != "" {
+		mux.Handle("/adapters/github", &githubAdapter{
+			secret: newSecretStore(namespace, githubSecret),
+		})
+	}
+
+	if mappingFile != "" {
+		config, err := loadMappingConfig(mappingFile)
+		if err != nil {
+			return err
+		}
+
+		// Mappings must not conflict with the built-in routes and other
+		// mappings, the path of GitHub adapter is reserved even if it is
+		// disabled.
+		routes := []string{"/api/v1alpha1/events", namespacedEventsPathPrefix, "/adapters/github"}
+		for i := range config.Mappings {
+			h := &mappingHandler{mapping: &config.Mappings[i]}
+			if err := checkRoute(h.mapping.Path, routes); err != nil {
+				return fmt.Errorf("invalid mapping: %s", err)
+			}
+			routes = append(routes, h.mapping.Path)
+			mux.HandleFunc(h.mapping.Path, authenticate(authenticators, h.ServeHTTP))
+		}
+	}
 
 	server := &http.Server{
 		Addr:    fmt.Sprintf("%s:%d", addr, port),
-		Handler: mux,
+		Handler: withNamespace(namespaceHeader, mux),
+	}
+
+	if authClientCAFile != "" {
+		// Client certificates are verified by the authenticator so that
+		// clients without certificate can use other authenticators.
+		server.TLSConfig = &tls.Config{
+			ClientAuth: tls.RequestClientCert,
+		}
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	if rateLimitFile != "" {
+		buf, err := ioutil.ReadFile(rateLimitFile)
+		if err != nil {
+			return err
+		}
+
+		config, err := parseRateLimitConfig(buf)
+		if err != nil {
+			return err
+		}
+
+		limiter = newRateLimiter(config)
+		server.Handler = limiter.Handler(server.Handler)
+		go limiter.Run(rateLimitFile, buf, stopCh)
 	}
 
 	go func() {
@@ -236,11 +696,27 @@ func main() {
 	}
 
 	flags := cmd.Flags()
-	flags.StringVarP(&namespace, "namespace", "n", "default", "The namespace to create Event resources")
+	flags.StringVarP(&namespace, "namespace", "n", "default", "The default namespace to create Event resources")
 	flags.StringVar(&addr, "bind-address", "0.0.0.0", "The IP address on which to listen")
 	flags.IntVar(&port, "port", 14380, "The port on which to listen")
 	flags.StringVar(&certFile, "tls-cert-file", "", "File containing the default x509 Certificate for HTTPS")
 	flags.StringVar(&keyFile, "tls-private-key-file", "", "File containing the default x509 private key matching --tls-cert-file")
+	flags.StringVar(&authTokenSecret, "auth-token-secret", "", "The name of Secret containing bearer tokens. Each key is the name of principal and its value is the token")
+	flags.StringVar(&authHMACSecret, "auth-hmac-secret", "", "The name of Secret containing HMAC keys to verify request signatures. The keys 'github', 'slack' and 'generic' enable each signature scheme")
+	flags.StringVar(&authClientCAFile, "auth-client-ca-file", "", "File containing the CA bundle to verify client certificates")
+	flags.BoolVar(&authTokenReview, "auth-token-review", false, "Authenticate bearer tokens with Kubernetes TokenReview API")
+	flags.StringSliceVar(&authTokenReviewAudiences, "auth-token-review-audiences", nil, "The audiences of bearer tokens authenticated with TokenReview API")
+	flags.StringVar(&githubSecret, "github-webhook-secret", "", "The name of Secret containing the webhook secret of GitHub in 'secret' key. GitHub adapter is enabled only if it is specified")
+	flags.StringVar(&mappingFile, "mapping-config", "", "File containing the mappings from webhook requests to events")
+	flags.StringVar(&routingFile, "routing-config", "", "File containing the namespaces that events are allowed to be created in and the rules to route events")
+	flags.StringVar(&namespaceHeader, "namespace-header", "X-Event-Namespace", "The request header to specify the namespace of events")
+	flags.StringSliceVar(&allowedOrigins, "webhook-allowed-origins", []string{"*"}, "The origins allowed to send events in the webhook validation handshake")
+	flags.DurationVar(&dedupeWindow, "dedupe-window", time.Hour, "The duration in which events with the same source and id are deduplicated. Set 0 to disable deduplication")
+	flags.StringVar(&rateLimitFile, "rate-limit-config", "", "File containing the rate limits. The file is reloaded when it is changed")
+	flags.Int64Var(&maxBodySize, "max-body-size", 1024*1024, "The maximum size of request body in bytes")
+	flags.StringVar(&offloadStore, "offload-store", "", "The data store to offload large event payloads. One of 'ConfigMap', 'Secret' and 'FileSystem'")
+	flags.Int64Var(&offloadThreshold, "offload-threshold", 256*1024, "The payload size in bytes above which the payload is offloaded to the data store")
+	flags.StringVar(&offloadDir, "offload-dir", "", "The directory of FileSystem data store. It must be shared with the controller")
 
 	err := cmd.Execute()
 	if err != nil {
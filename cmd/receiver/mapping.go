@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/jsonpath"
+	"sigs.k8s.io/yaml"
+
+	"github.com/summerwind/eventreactor/api/v1alpha1"
+	rtemplate "github.com/summerwind/eventreactor/pkg/template"
+)
+
+// MappingConfig is the configuration of mappings from webhook requests to
+// events. It is usually loaded from a ConfigMap mounted as a file.
+type MappingConfig struct {
+	// Mappings is the list of mappings.
+	Mappings []Mapping `json:"mappings"`
+}
+
+// Mapping represents the mapping from webhook requests on a URL path to
+// events.
+type Mapping struct {
+	// Path is the URL path of webhook.
+	Path string `json:"path"`
+	// ID is the mapping of the ID of event. A unique ID is generated if it
+	// is not specified.
+	ID *AttributeMapping `json:"id,omitempty"`
+	// Type is the mapping of the type of event.
+	Type *AttributeMapping `json:"type"`
+	// Source is the mapping of the source of event.
+	Source *AttributeMapping `json:"source"`
+	// Subject is the mapping of the subject of event.
+	Subject *AttributeMapping `json:"subject,omitempty"`
+	// Time is the mapping of the time of event in RFC 3339 format. The
+	// time of request is used if it is not specified.
+	Time *AttributeMapping `json:"time,omitempty"`
+}
+
+// AttributeMapping represents how to extract the value of an attribute
+// from webhook requests. Exactly one of fields must be specified.
+type AttributeMapping struct {
+	// Value is the constant value.
+	Value string `json:"value,omitempty"`
+	// Header is the name of request header.
+	Header string `json:"header,omitempty"`
+	// JSONPath is the JSONPath expression that is evaluated against the
+	// request body, such as '{.repository.id}'.
+	JSONPath string `json:"jsonPath,omitempty"`
+	// Template is the template rendered with '.Header' and '.Data' that
+	// are the request headers and the decoded request body.
+	Template string `json:"template,omitempty"`
+
+	jsonPath *jsonpath.JSONPath
+	template *template.Template
+}
+
+// mappingVars is the variables for the template of attributes.
+type mappingVars struct {
+	Header http.Header
+	Data   interface{}
+}
+
+// loadMappingConfig loads the mapping configuration from the file.
+func loadMappingConfig(path string) (*MappingConfig, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := MappingConfig{}
+	if err := yaml.Unmarshal(buf, &config); err != nil {
+		return nil, fmt.Errorf("invalid mapping config: %s", err)
+	}
+
+	paths := map[string]bool{}
+	for i := range config.Mappings {
+		m := &config.Mappings[i]
+
+		if m.Path == "" || !strings.HasPrefix(m.Path, "/") {
+			return nil, fmt.Errorf("mappings[%d]: path must be an absolute URL path", i)
+		}
+		if paths[m.Path] {
+			return nil, fmt.Errorf("mappings[%d]: duplicate path: %s", i, m.Path)
+		}
+		paths[m.Path] = true
+
+		if m.Type == nil {
+			return nil, fmt.Errorf("mappings[%d]: type must be specified", i)
+		}
+		if m.Source == nil {
+			return nil, fmt.Errorf("mappings[%d]: source must be specified", i)
+		}
+
+		attrs := map[string]*AttributeMapping{
+			"id":      m.ID,
+			"type":    m.Type,
+			"source":  m.Source,
+			"subject": m.Subject,
+			"time":    m.Time,
+		}
+		for name, attr := range attrs {
+			if attr == nil {
+				continue
+			}
+			if err := attr.compile(); err != nil {
+				return nil, fmt.Errorf("mappings[%d].%s: %s", i, name, err)
+			}
+		}
+	}
+
+	return &config, nil
+}
+
+// checkRoute returns an error if the path conflicts with the registered
+// routes. Routes that end with '/' match all the paths under them.
+func checkRoute(path string, routes []string) error {
+	for _, route := range routes {
+		if path == route {
+			return fmt.Errorf("path is already registered: %s", path)
+		}
+		if strings.HasSuffix(route, "/") && strings.HasPrefix(path, route) {
+			return fmt.Errorf("path conflicts with %s: %s", route, path)
+		}
+	}
+
+	return nil
+}
+
+// compile validates the attribute mapping and parses its expression.
+func (a *AttributeMapping) compile() error {
+	n := 0
+	for _, v := range []string{a.Value, a.Header, a.JSONPath, a.Template} {
+		if v != "" {
+			n++
+		}
+	}
+	if n != 1 {
+		return errors.New("exactly one of value, header, jsonPath or template must be specified")
+	}
+
+	if a.JSONPath != "" {
+		expr := a.JSONPath
+		if !strings.HasPrefix(expr, "{") {
+			expr = fmt.Sprintf("{%s}", expr)
+		}
+
+		jp := jsonpath.New("attribute").AllowMissingKeys(true)
+		if err := jp.Parse(expr); err != nil {
+			return fmt.Errorf("invalid JSONPath: %s", err)
+		}
+		a.jsonPath = jp
+	}
+
+	if a.Template != "" {
+		tmpl, err := rtemplate.New("attribute").Option("missingkey=zero").Parse(a.Template)
+		if err != nil {
+			return fmt.Errorf("invalid template: %s", err)
+		}
+		a.template = tmpl
+	}
+
+	return nil
+}
+
+// extract returns the value of attribute from the request.
+func (a *AttributeMapping) extract(vars *mappingVars) (string, error) {
+	if a == nil {
+		return "", nil
+	}
+
+	switch {
+	case a.Header != "":
+		return vars.Header.Get(a.Header), nil
+	case a.jsonPath != nil:
+		if vars.Data == nil {
+			return "", nil
+		}
+
+		buf := bytes.NewBuffer([]byte{})
+		if err := a.jsonPath.Execute(buf, vars.Data); err != nil {
+			return "", err
+		}
+
+		return strings.TrimSpace(buf.String()), nil
+	case a.template != nil:
+		buf := bytes.NewBuffer([]byte{})
+		if err := a.template.Execute(buf, vars); err != nil {
+			return "", err
+		}
+
+		return strings.TrimSpace(buf.String()), nil
+	}
+
+	return a.Value, nil
+}
+
+// mappingHandler converts webhook requests into events with the mapping.
+type mappingHandler struct {
+	mapping *Mapping
+}
+
+func (h *mappingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reqLog := log.WithValues("remote_addr", r.RemoteAddr, "path", h.mapping.Path)
+
+	if r.Method == http.MethodOptions {
+		handleOptions(w, reqLog, r)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, reqLog, r)
+		return
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		writeError(w, reqLog, err)
+		return
+	}
+
+	ev, err := h.newEvent(r.Header, body)
+	if err != nil {
+		writeError(w, reqLog, err)
+		return
+	}
+
+	created, err := createEvent(r.Context(), ev)
+	if err != nil {
+		writeError(w, reqLog.WithValues("namespace", ev.Namespace), err)
+		return
+	}
+	if !created {
+		reqLog.Info("Duplicate event", "name", ev.Name, "namespace", ev.Namespace)
+		writeEvent(w, reqLog, ev, http.StatusOK)
+		return
+	}
+
+	reqLog.Info("Event resource created", "name", ev.Name, "namespace", ev.Namespace, "type", ev.Spec.Type)
+	writeEvent(w, reqLog, ev, http.StatusAccepted)
+}
+
+// newEvent returns a new event from the headers and the body of request.
+func (h *mappingHandler) newEvent(header http.Header, body []byte) (*v1alpha1.Event, error) {
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/json"
+	}
+
+	vars := &mappingVars{Header: header}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, unsupportedMediaType(contentType)
+	}
+	if (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")) && len(body) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		if err := dec.Decode(&vars.Data); err != nil {
+			return nil, badRequest("data", "invalid JSON body: %s", err)
+		}
+	}
+
+	attrs := map[string]string{}
+	mappings := map[string]*AttributeMapping{
+		"id":      h.mapping.ID,
+		"type":    h.mapping.Type,
+		"source":  h.mapping.Source,
+		"subject": h.mapping.Subject,
+		"time":    h.mapping.Time,
+	}
+	for name, m := range mappings {
+		v, err := m.extract(vars)
+		if err != nil {
+			return nil, badRequest(name, "failed to extract value: %s", err)
+		}
+		attrs[name] = v
+	}
+
+	if attrs["type"] == "" {
+		return nil, badRequest("type", "type must not be empty")
+	}
+	if attrs["source"] == "" {
+		return nil, badRequest("source", "source must not be empty")
+	}
+
+	ev := v1alpha1.Event{}
+	ev.Spec = v1alpha1.EventSpec{
+		ID:              attrs["id"],
+		Source:          attrs["source"],
+		Type:            attrs["type"],
+		DataContentType: contentType,
+		Subject:         attrs["subject"],
+	}
+	setEventData(&ev, contentType, body)
+
+	if ev.Spec.ID == "" {
+		ev.Spec.ID = v1alpha1.NewEventName()
+	}
+
+	t := metav1.NewTime(time.Now())
+	if attrs["time"] != "" {
+		et, err := time.Parse(time.RFC3339, attrs["time"])
+		if err != nil {
+			return nil, badRequest("time", "invalid time: %s", attrs["time"])
+		}
+		t = metav1.NewTime(et)
+	}
+	ev.Spec.Time = &t
+
+	return &ev, nil
+}
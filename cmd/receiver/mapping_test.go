@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"testing"
+)
+
+func TestCheckRoute(t *testing.T) {
+	routes := []string{"/api/v1alpha1/events", namespacedEventsPathPrefix, "/adapters/github", "/hooks/a"}
+
+	tests := []struct {
+		path string
+		err  bool
+	}{
+		{"/hooks/b", false},
+		{"/api/v1alpha1/namespaces", false},
+		{"/api/v1alpha1/events", true},
+		{"/adapters/github", true},
+		{"/hooks/a", true},
+		{"/api/v1alpha1/namespaces/", true},
+		{"/api/v1alpha1/namespaces/default/events", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			err := checkRoute(tt.path, routes)
+			if (err != nil) != tt.err {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestMappingNewEvent(t *testing.T) {
+	mapping := &Mapping{
+		Path:   "/hooks/test",
+		Type:   &AttributeMapping{Value: "com.example.test"},
+		Source: &AttributeMapping{Header: "X-Source"},
+	}
+	for _, attr := range []*AttributeMapping{mapping.Type, mapping.Source} {
+		if err := attr.compile(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		data        string
+		dataBase64  string
+		err         bool
+	}{
+		{
+			name:        "json",
+			contentType: "application/json",
+			body:        `{"a":1}`,
+			data:        `{"a":1}`,
+		},
+		{
+			name:        "text",
+			contentType: "text/plain",
+			body:        "hello",
+			data:        "hello",
+		},
+		{
+			name:        "binary",
+			contentType: "application/octet-stream",
+			body:        "\x00\x01",
+			dataBase64:  base64.StdEncoding.EncodeToString([]byte("\x00\x01")),
+		},
+		{
+			name:        "invalid utf-8 text",
+			contentType: "text/plain",
+			body:        "\xff",
+			dataBase64:  base64.StdEncoding.EncodeToString([]byte("\xff")),
+		},
+		{
+			name:        "invalid json",
+			contentType: "application/json",
+			body:        `{`,
+			err:         true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set("Content-Type", tt.contentType)
+			header.Set("X-Source", "/test")
+
+			h := &mappingHandler{mapping: mapping}
+			ev, err := h.newEvent(header, []byte(tt.body))
+			if (err != nil) != tt.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.err {
+				return
+			}
+
+			if ev.Spec.Type != "com.example.test" || ev.Spec.Source != "/test" {
+				t.Errorf("unexpected attributes: type=%q source=%q", ev.Spec.Type, ev.Spec.Source)
+			}
+			if ev.Spec.Data != tt.data {
+				t.Errorf("data = %q, want %q", ev.Spec.Data, tt.data)
+			}
+			if ev.Spec.DataBase64 != tt.dataBase64 {
+				t.Errorf("data_base64 = %q, want %q", ev.Spec.DataBase64, tt.dataBase64)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/summerwind/eventreactor/api/v1alpha1"
+	"github.com/summerwind/eventreactor/pkg/blob"
+)
+
+// offloadData stores the payload of the event in the data store if its
+// size exceeds the threshold, and replaces the payload with the reference
+// to the stored data. It returns true if the payload was offloaded.
+func offloadData(ctx context.Context, ev *v1alpha1.Event) (bool, error) {
+	if dataStore == nil {
+		return false, nil
+	}
+
+	data := []byte(ev.Spec.Data)
+	if ev.Spec.DataBase64 != "" {
+		b, err := base64.StdEncoding.DecodeString(ev.Spec.DataBase64)
+		if err != nil {
+			return false, badRequest("data", "invalid base64 data: %s", err)
+		}
+		data = b
+	}
+
+	if int64(len(data)) <= offloadThreshold {
+		return false, nil
+	}
+
+	if err := dataStore.Put(ctx, ev.Namespace, ev.Name, data); err != nil {
+		return false, err
+	}
+
+	ev.Spec.Data = ""
+	ev.Spec.DataBase64 = ""
+	ev.Spec.DataRef = &v1alpha1.DataReference{
+		Store: dataStore.Type(),
+		Name:  ev.Name,
+		Size:  int64(len(data)),
+	}
+
+	return true, nil
+}
+
+// adoptData sets the owner reference of the stored payload to the event so
+// that the payload is deleted with the event.
+func adoptData(ctx context.Context, ev *v1alpha1.Event) error {
+	store, ok := dataStore.(blob.OwnedStore)
+	if !ok {
+		return nil
+	}
+
+	owner := metav1.OwnerReference{
+		APIVersion: v1alpha1.GroupVersion.String(),
+		Kind:       "Event",
+		Name:       ev.Name,
+		UID:        ev.UID,
+	}
+
+	return store.SetOwner(ctx, ev.Namespace, ev.Name, owner)
+}
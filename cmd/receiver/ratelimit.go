@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"sigs.k8s.io/yaml"
+
+	"github.com/summerwind/eventreactor/api/v1alpha1"
+)
+
+const (
+	// Keys of rate limits.
+	rateLimitKeySource    = "source"
+	rateLimitKeyIP        = "ip"
+	rateLimitKeyPrincipal = "principal"
+
+	// The interval to check the update of rate limit config.
+	rateLimitReloadInterval = 10 * time.Second
+	// The duration after which idle token buckets are removed.
+	rateLimitBucketTTL = 10 * time.Minute
+	// The maximum number of token buckets. The least recently used bucket
+	// is removed if it is exceeded, since the keys such as source are
+	// specified by clients.
+	rateLimitMaxBuckets = 10000
+)
+
+type clientIPKey struct{}
+
+// RateLimitConfig is the configuration of rate limits.
+type RateLimitConfig struct {
+	// MaxConcurrency is the maximum number of requests processed
+	// concurrently. It is unlimited if it is zero.
+	MaxConcurrency int `json:"maxConcurrency,omitempty"`
+	// Limits is the list of token bucket rate limits. An event is accepted
+	// only if all the limits allow it.
+	Limits []RateLimit `json:"limits,omitempty"`
+}
+
+// RateLimit represents a token bucket rate limit for each key.
+type RateLimit struct {
+	// Key is the key of token buckets. One of 'source', 'ip' and
+	// 'principal'.
+	Key string `json:"key"`
+	// Rate is the number of events allowed per second.
+	Rate float64 `json:"rate"`
+	// Burst is the maximum number of events allowed at once.
+	Burst int `json:"burst"`
+}
+
+// rateLimitError is returned if the event is rejected by rate limits.
+type rateLimitError struct {
+	retryAfter time.Duration
+}
+
+func (e *rateLimitError) Error() string {
+	return fmt.Sprintf("rate limit exceeded, retry after %s", e.retryAfter)
+}
+
+// RetryAfter returns the value of Retry-After header in seconds.
+func (e *rateLimitError) RetryAfter() string {
+	return strconv.Itoa(int(math.Ceil(e.retryAfter.Seconds())))
+}
+
+type bucket struct {
+	key      string
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// rateLimiter limits the rate of events and the number of concurrent
+// requests. The configuration can be replaced at runtime.
+type rateLimiter struct {
+	mu     sync.Mutex
+	config *RateLimitConfig
+	sem    chan struct{}
+
+	// buckets is the index of token buckets in lru that is ordered from
+	// the most recently used.
+	buckets    map[string]*list.Element
+	lru        *list.List
+	maxBuckets int
+}
+
+func newRateLimiter(config *RateLimitConfig) *rateLimiter {
+	l := &rateLimiter{maxBuckets: rateLimitMaxBuckets}
+	l.SetConfig(config)
+	return l
+}
+
+// parseRateLimitConfig parses the rate limit configuration.
+func parseRateLimitConfig(buf []byte) (*RateLimitConfig, error) {
+	config := RateLimitConfig{}
+	if err := yaml.Unmarshal(buf, &config); err != nil {
+		return nil, fmt.Errorf("invalid rate limit config: %s", err)
+	}
+
+	if config.MaxConcurrency < 0 {
+		return nil, errors.New("maxConcurrency must not be negative")
+	}
+
+	for i, limit := range config.Limits {
+		switch limit.Key {
+		case rateLimitKeySource, rateLimitKeyIP, rateLimitKeyPrincipal:
+		default:
+			return nil, fmt.Errorf("limits[%d]: invalid key: %s", i, limit.Key)
+		}
+		if limit.Rate <= 0 {
+			return nil, fmt.Errorf("limits[%d]: rate must be positive", i)
+		}
+		if limit.Burst <= 0 {
+			return nil, fmt.Errorf("limits[%d]: burst must be positive", i)
+		}
+	}
+
+	return &config, nil
+}
+
+// SetConfig replaces the configuration. Token buckets are reset, and
+// requests in progress are not counted by the new concurrency limit.
+func (l *rateLimiter) SetConfig(config *RateLimitConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.config = config
+	l.buckets = map[string]*list.Element{}
+	l.lru = list.New()
+	l.sem = nil
+	if config.MaxConcurrency > 0 {
+		l.sem = make(chan struct{}, config.MaxConcurrency)
+	}
+}
+
+// Handler returns a handler that limits the number of concurrent requests.
+// It also stores the client IP in the context for the rate limits.
+func (l *rateLimiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			r = r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip))
+		}
+
+		l.mu.Lock()
+		sem := l.sem
+		l.mu.Unlock()
+
+		if sem != nil {
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			default:
+				writeError(w, log.WithValues("remote_addr", r.RemoteAddr), &rateLimitError{retryAfter: time.Second})
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Allow returns rateLimitError if the event exceeds any of rate limits.
+func (l *rateLimiter) Allow(ctx context.Context, ev *v1alpha1.Event) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	reservations := []*rate.Reservation{}
+	var retryAfter time.Duration
+
+	for i, limit := range l.config.Limits {
+		var value string
+		switch limit.Key {
+		case rateLimitKeySource:
+			value = ev.Spec.Source
+		case rateLimitKeyIP:
+			value, _ = ctx.Value(clientIPKey{}).(string)
+		case rateLimitKeyPrincipal:
+			value = principalFromContext(ctx)
+		}
+
+		key := fmt.Sprintf("%d/%s", i, value)
+		b := l.bucket(key, limit)
+		b.lastSeen = now
+
+		r := b.limiter.ReserveN(now, 1)
+		reservations = append(reservations, r)
+		if delay := r.DelayFrom(now); delay > retryAfter {
+			retryAfter = delay
+		}
+	}
+
+	if retryAfter > 0 {
+		// Return the tokens so that rejected events do not consume them.
+		for _, r := range reservations {
+			r.CancelAt(now)
+		}
+		return &rateLimitError{retryAfter: retryAfter}
+	}
+
+	return nil
+}
+
+// bucket returns the token bucket of the key. A new bucket is created if it
+// does not exist, and the least recently used bucket is removed if the
+// number of buckets exceeds the limit.
+func (l *rateLimiter) bucket(key string, limit RateLimit) *bucket {
+	if e, ok := l.buckets[key]; ok {
+		l.lru.MoveToFront(e)
+		return e.Value.(*bucket)
+	}
+
+	for l.lru.Len() >= l.maxBuckets {
+		l.remove(l.lru.Back())
+	}
+
+	b := &bucket{
+		key:     key,
+		limiter: rate.NewLimiter(rate.Limit(limit.Rate), limit.Burst),
+	}
+	l.buckets[key] = l.lru.PushFront(b)
+
+	return b
+}
+
+// remove removes the token bucket in the element of lru.
+func (l *rateLimiter) remove(e *list.Element) {
+	l.lru.Remove(e)
+	delete(l.buckets, e.Value.(*bucket).key)
+}
+
+// cleanup removes idle token buckets.
+func (l *rateLimiter) cleanup() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	expired := time.Now().Add(-rateLimitBucketTTL)
+	for e := l.lru.Back(); e != nil && e.Value.(*bucket).lastSeen.Before(expired); e = l.lru.Back() {
+		l.remove(e)
+	}
+}
+
+// Run reloads the configuration from the file when it is changed and
+// removes idle token buckets until the stop channel is closed.
+func (l *rateLimiter) Run(path string, current []byte, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(rateLimitReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		l.cleanup()
+
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.Error(err, "Failed to read rate limit config")
+			continue
+		}
+		if bytes.Equal(buf, current) {
+			continue
+		}
+
+		config, err := parseRateLimitConfig(buf)
+		if err != nil {
+			log.Error(err, "Failed to reload rate limit config")
+			continue
+		}
+
+		l.SetConfig(config)
+		current = buf
+		log.Info("Rate limit config reloaded")
+	}
+}
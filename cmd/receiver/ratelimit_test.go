@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/summerwind/eventreactor/api/v1alpha1"
+)
+
+func newTestEvent(source string) *v1alpha1.Event {
+	ev := &v1alpha1.Event{}
+	ev.Spec.Source = source
+	return ev
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	l := newRateLimiter(&RateLimitConfig{
+		Limits: []RateLimit{
+			{Key: rateLimitKeySource, Rate: 0.001, Burst: 2},
+		},
+	})
+
+	tests := []struct {
+		source string
+		err    bool
+	}{
+		{"/a", false},
+		{"/a", false},
+		{"/a", true},
+		{"/b", false},
+		{"/a", true},
+	}
+
+	for i, tt := range tests {
+		err := l.Allow(context.Background(), newTestEvent(tt.source))
+		if (err != nil) != tt.err {
+			t.Errorf("%d: unexpected error: %v", i, err)
+		}
+		if err != nil {
+			if _, ok := err.(*rateLimitError); !ok {
+				t.Errorf("%d: unexpected error type: %T", i, err)
+			}
+		}
+	}
+}
+
+func TestRateLimiterMaxBuckets(t *testing.T) {
+	l := newRateLimiter(&RateLimitConfig{
+		Limits: []RateLimit{
+			{Key: rateLimitKeySource, Rate: 0.001, Burst: 1},
+		},
+	})
+	l.maxBuckets = 3
+
+	for i := 0; i < 10; i++ {
+		if err := l.Allow(context.Background(), newTestEvent(fmt.Sprintf("/%d", i))); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if len(l.buckets) != 3 || l.lru.Len() != 3 {
+		t.Fatalf("number of buckets = %d/%d, want 3", len(l.buckets), l.lru.Len())
+	}
+	for _, source := range []string{"/7", "/8", "/9"} {
+		if _, ok := l.buckets["0/"+source]; !ok {
+			t.Errorf("bucket of %s not found", source)
+		}
+	}
+
+	// The recently used bucket is kept and its tokens are not reset.
+	if err := l.Allow(context.Background(), newTestEvent("/7")); err == nil {
+		t.Errorf("expected rate limit error for /7")
+	}
+	if err := l.Allow(context.Background(), newTestEvent("/10")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := l.buckets["0//7"]; !ok {
+		t.Errorf("recently used bucket was removed")
+	}
+	if _, ok := l.buckets["0//8"]; ok {
+		t.Errorf("least recently used bucket was not removed")
+	}
+}
+
+func TestRateLimiterCleanup(t *testing.T) {
+	l := newRateLimiter(&RateLimitConfig{
+		Limits: []RateLimit{
+			{Key: rateLimitKeySource, Rate: 1, Burst: 1},
+		},
+	})
+
+	for _, source := range []string{"/old", "/new"} {
+		if err := l.Allow(context.Background(), newTestEvent(source)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	l.buckets["0//old"].Value.(*bucket).lastSeen = time.Now().Add(-2 * rateLimitBucketTTL)
+
+	l.cleanup()
+
+	if _, ok := l.buckets["0//old"]; ok {
+		t.Errorf("idle bucket was not removed")
+	}
+	if _, ok := l.buckets["0//new"]; !ok {
+		t.Errorf("active bucket was removed")
+	}
+}
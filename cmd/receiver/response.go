@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/summerwind/eventreactor/api/v1alpha1"
+)
+
+const problemContentType = "application/problem+json"
+
+// Problem is the problem details of an error response defined in RFC 7807.
+type Problem struct {
+	// Type is the URI reference that identifies the problem type.
+	Type string `json:"type"`
+	// Title is the short summary of the problem type.
+	Title string `json:"title"`
+	// Status is the HTTP status code.
+	Status int `json:"status"`
+	// Detail is the explanation of the problem.
+	Detail string `json:"detail,omitempty"`
+	// Attribute is the name of CloudEvents attribute that caused the
+	// problem.
+	Attribute string `json:"attribute,omitempty"`
+}
+
+// EventResponse is the response body of the accepted event.
+type EventResponse struct {
+	// Name is the name of created event resource.
+	Name string `json:"name"`
+	// Namespace is the namespace of created event resource.
+	Namespace string `json:"namespace"`
+}
+
+// requestError is the error caused by an invalid request.
+type requestError struct {
+	status    int
+	attribute string
+	message   string
+}
+
+func (e *requestError) Error() string {
+	return e.message
+}
+
+// badRequest returns an error of invalid attribute.
+func badRequest(attribute, format string, args ...interface{}) error {
+	return &requestError{
+		status:    http.StatusBadRequest,
+		attribute: attribute,
+		message:   fmt.Sprintf(format, args...),
+	}
+}
+
+// unsupportedMediaType returns an error of unsupported content type.
+func unsupportedMediaType(contentType string) error {
+	return &requestError{
+		status:    http.StatusUnsupportedMediaType,
+		attribute: "datacontenttype",
+		message:   fmt.Sprintf("unsupported content-type: %s", contentType),
+	}
+}
+
+// readBody reads the request body up to the maximum size.
+func readBody(r *http.Request) ([]byte, error) {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(body)) > maxBodySize {
+		return nil, &requestError{
+			status:  http.StatusRequestEntityTooLarge,
+			message: fmt.Sprintf("request body exceeds %d bytes", maxBodySize),
+		}
+	}
+
+	return body, nil
+}
+
+// errorStatus returns the HTTP status code and the attribute that caused
+// the error.
+func errorStatus(err error) (int, string) {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		return reqErr.status, reqErr.attribute
+	}
+
+	if errors.Is(err, errForbidden) {
+		return http.StatusForbidden, ""
+	}
+
+	var rateErr *rateLimitError
+	if errors.As(err, &rateErr) {
+		return http.StatusTooManyRequests, ""
+	}
+
+	// The event is rejected by the admission webhook.
+	if apierrors.IsInvalid(err) {
+		attribute := ""
+		if status, ok := err.(apierrors.APIStatus); ok {
+			details := status.Status().Details
+			if details != nil && len(details.Causes) > 0 {
+				attribute = strings.ToLower(strings.TrimPrefix(details.Causes[0].Field, "spec."))
+			}
+		}
+		return http.StatusBadRequest, attribute
+	}
+
+	return http.StatusInternalServerError, ""
+}
+
+// writeError writes the error response in the problem details format.
+func writeError(w http.ResponseWriter, reqLog logr.Logger, err error) {
+	status, attribute := errorStatus(err)
+
+	var rateErr *rateLimitError
+	if errors.As(err, &rateErr) {
+		w.Header().Set("Retry-After", rateErr.RetryAfter())
+	}
+
+	detail := err.Error()
+	if status >= http.StatusInternalServerError {
+		reqLog.Error(err, "Failed to process request")
+		detail = ""
+	} else {
+		reqLog.Info("Invalid request", "status", status, "error", detail)
+	}
+
+	writeProblem(w, reqLog, &Problem{
+		Status:    status,
+		Detail:    detail,
+		Attribute: attribute,
+	})
+}
+
+// writeProblem writes the problem details as the response.
+func writeProblem(w http.ResponseWriter, reqLog logr.Logger, p *Problem) {
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(p.Status)
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		reqLog.Error(err, "Failed to write response")
+	}
+}
+
+// writeEvent writes the response of the accepted event. The status is 202
+// for a new event and 200 for a duplicate event.
+func writeEvent(w http.ResponseWriter, reqLog logr.Logger, ev *v1alpha1.Event, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	res := EventResponse{Name: ev.Name, Namespace: ev.Namespace}
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		reqLog.Error(err, "Failed to write response")
+	}
+}
+
+// methodNotAllowed writes the response of the unsupported request method.
+func methodNotAllowed(w http.ResponseWriter, reqLog logr.Logger, r *http.Request) {
+	reqLog.V(1).Info("Invalid request method", "method", r.Method)
+
+	w.Header().Set("Allow", "OPTIONS, POST")
+	writeProblem(w, reqLog, &Problem{
+		Status: http.StatusMethodNotAllowed,
+		Detail: fmt.Sprintf("method %s is not allowed", r.Method),
+	})
+}
+
+// handleOptions handles the validation handshake of CloudEvents webhook.
+// The origin in 'WebHook-Request-Origin' header is allowed if it matches
+// the allowed origins.
+func handleOptions(w http.ResponseWriter, reqLog logr.Logger, r *http.Request) {
+	w.Header().Set("Allow", "OPTIONS, POST")
+
+	origin := r.Header.Get("WebHook-Request-Origin")
+	if origin == "" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	for _, allowed := range allowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			w.Header().Set("WebHook-Allowed-Origin", origin)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	}
+
+	reqLog.Info("Webhook origin is not allowed", "origin", origin)
+	writeProblem(w, reqLog, &Problem{
+		Status: http.StatusForbidden,
+		Detail: fmt.Sprintf("origin %s is not allowed", origin),
+	})
+}
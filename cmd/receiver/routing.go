@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/summerwind/eventreactor/api/v1alpha1"
+)
+
+const namespacedEventsPathPrefix = "/api/v1alpha1/namespaces/"
+
+// errForbidden is returned if the event is not allowed to be created in the
+// namespace.
+var errForbidden = errors.New("forbidden")
+
+type namespaceKey struct{}
+
+// RoutingConfig is the configuration of routing events to namespaces.
+type RoutingConfig struct {
+	// Namespaces is the list of namespaces that events are allowed to be
+	// created in, in addition to the default namespace.
+	Namespaces []NamespaceRoute `json:"namespaces"`
+	// Rules is the list of rules to route events by their attributes. The
+	// first matched rule is used.
+	Rules []RoutingRule `json:"rules,omitempty"`
+}
+
+// NamespaceRoute represents a namespace that events are allowed to be
+// created in.
+type NamespaceRoute struct {
+	// Name is the name of namespace.
+	Name string `json:"name"`
+	// Principals is the list of authenticated principals that are allowed
+	// to create events in the namespace. Any principal is allowed if it is
+	// empty.
+	Principals []string `json:"principals,omitempty"`
+}
+
+// RoutingRule represents a rule to route events by their attributes.
+type RoutingRule struct {
+	// Namespace is the namespace of events that match the rule.
+	Namespace string `json:"namespace"`
+	// MatchSource is the regular expression to match the source of event.
+	MatchSource string `json:"matchSource,omitempty"`
+	// MatchType is the regular expression to match the type of event.
+	MatchType string `json:"matchType,omitempty"`
+
+	source *regexp.Regexp
+	typ    *regexp.Regexp
+}
+
+// namespaceRouter decides the namespace of events.
+type namespaceRouter struct {
+	defaultNamespace string
+	routes           map[string]*NamespaceRoute
+	rules            []RoutingRule
+}
+
+// newNamespaceRouter returns a new router. Only the default namespace is
+// allowed if config is nil.
+func newNamespaceRouter(defaultNamespace string, config *RoutingConfig) *namespaceRouter {
+	router := &namespaceRouter{
+		defaultNamespace: defaultNamespace,
+		routes: map[string]*NamespaceRoute{
+			defaultNamespace: {Name: defaultNamespace},
+		},
+	}
+
+	if config != nil {
+		for i := range config.Namespaces {
+			router.routes[config.Namespaces[i].Name] = &config.Namespaces[i]
+		}
+		router.rules = config.Rules
+	}
+
+	return router
+}
+
+// loadRoutingConfig loads the routing configuration from the file.
+func loadRoutingConfig(path string) (*RoutingConfig, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := RoutingConfig{}
+	if err := yaml.Unmarshal(buf, &config); err != nil {
+		return nil, fmt.Errorf("invalid routing config: %s", err)
+	}
+
+	allowed := map[string]bool{}
+	for i, ns := range config.Namespaces {
+		if ns.Name == "" {
+			return nil, fmt.Errorf("namespaces[%d]: name must be specified", i)
+		}
+		allowed[ns.Name] = true
+	}
+
+	for i := range config.Rules {
+		rule := &config.Rules[i]
+
+		if rule.Namespace == "" {
+			return nil, fmt.Errorf("rules[%d]: namespace must be specified", i)
+		}
+		if !allowed[rule.Namespace] {
+			return nil, fmt.Errorf("rules[%d]: namespace is not in the list of namespaces: %s", i, rule.Namespace)
+		}
+
+		if rule.MatchSource != "" {
+			re, err := regexp.Compile(rule.MatchSource)
+			if err != nil {
+				return nil, fmt.Errorf("rules[%d]: invalid matchSource: %s", i, err)
+			}
+			rule.source = re
+		}
+
+		if rule.MatchType != "" {
+			re, err := regexp.Compile(rule.MatchType)
+			if err != nil {
+				return nil, fmt.Errorf("rules[%d]: invalid matchType: %s", i, err)
+			}
+			rule.typ = re
+		}
+	}
+
+	return &config, nil
+}
+
+// Route returns the namespace of the event. The namespace specified in the
+// request takes precedence over the rules, and the default namespace is
+// used if nothing matches. errForbidden is returned if the namespace is not
+// allowed or the principal is not allowed to use the namespace.
+func (nr *namespaceRouter) Route(ctx context.Context, ev *v1alpha1.Event) (string, error) {
+	ns := namespaceFromContext(ctx)
+
+	if ns == "" {
+		for _, rule := range nr.rules {
+			if rule.source != nil && !rule.source.MatchString(ev.Spec.Source) {
+				continue
+			}
+			if rule.typ != nil && !rule.typ.MatchString(ev.Spec.Type) {
+				continue
+			}
+
+			ns = rule.Namespace
+			break
+		}
+	}
+
+	if ns == "" {
+		ns = nr.defaultNamespace
+	}
+
+	route, ok := nr.routes[ns]
+	if !ok {
+		return "", fmt.Errorf("%w: namespace %s is not allowed", errForbidden, ns)
+	}
+
+	if len(route.Principals) > 0 {
+		principal := principalFromContext(ctx)
+
+		allowed := false
+		for _, p := range route.Principals {
+			if p == principal {
+				allowed = true
+				break
+			}
+		}
+
+		if !allowed {
+			return "", fmt.Errorf("%w: principal '%s' is not allowed to use namespace %s", errForbidden, principal, ns)
+		}
+	}
+
+	return ns, nil
+}
+
+// namespaceFromContext returns the namespace requested by the request
+// header or the URL path. It returns an empty string if no namespace is
+// requested.
+func namespaceFromContext(ctx context.Context) string {
+	ns, _ := ctx.Value(namespaceKey{}).(string)
+	return ns
+}
+
+// withNamespace returns a handler that stores the namespace in the request
+// header to the context of request.
+func withNamespace(header string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ns := r.Header.Get(header); header != "" && ns != "" {
+			r = r.WithContext(context.WithValue(r.Context(), namespaceKey{}, ns))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// namespacedEventHandler returns a handler of the event endpoint that has
+// the namespace in the URL path, such as
+// '/api/v1alpha1/namespaces/{namespace}/events'.
+func namespacedEventHandler(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, namespacedEventsPathPrefix), "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] != "events" {
+			writeProblem(w, log.WithValues("remote_addr", r.RemoteAddr), &Problem{Status: http.StatusNotFound})
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), namespaceKey{}, parts[0])))
+	}
+}
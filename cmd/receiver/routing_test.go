@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/summerwind/eventreactor/api/v1alpha1"
+)
+
+func TestNamespaceRouter(t *testing.T) {
+	config := &RoutingConfig{
+		Namespaces: []NamespaceRoute{
+			{Name: "github"},
+			{Name: "ci", Principals: []string{"token:ci"}},
+		},
+		Rules: []RoutingRule{
+			{Namespace: "ci", typ: regexp.MustCompile(`^com\.example\.ci\.`)},
+			{Namespace: "github", source: regexp.MustCompile(`^https://github\.com/`)},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		config    *RoutingConfig
+		namespace string
+		principal string
+		source    string
+		typ       string
+		want      string
+		err       bool
+	}{
+		{
+			name:   "default namespace without config",
+			source: "https://github.com/summerwind/eventreactor",
+			typ:    "com.github.push",
+			want:   "default",
+		},
+		{
+			name:      "namespace is not allowed without config",
+			namespace: "github",
+			err:       true,
+		},
+		{
+			name:   "default namespace",
+			config: config,
+			source: "/test",
+			typ:    "test",
+			want:   "default",
+		},
+		{
+			name:   "rule by source",
+			config: config,
+			source: "https://github.com/summerwind/eventreactor",
+			typ:    "com.github.push",
+			want:   "github",
+		},
+		{
+			name:      "rule by type with allowed principal",
+			config:    config,
+			principal: "token:ci",
+			source:    "https://github.com/summerwind/eventreactor",
+			typ:       "com.example.ci.build",
+			want:      "ci",
+		},
+		{
+			name:      "rule by type with forbidden principal",
+			config:    config,
+			principal: "token:other",
+			typ:       "com.example.ci.build",
+			err:       true,
+		},
+		{
+			name:      "namespace in request takes precedence",
+			config:    config,
+			namespace: "default",
+			source:    "https://github.com/summerwind/eventreactor",
+			want:      "default",
+		},
+		{
+			name:      "namespace in request is not allowed",
+			config:    config,
+			namespace: "kube-system",
+			err:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.namespace != "" {
+				ctx = context.WithValue(ctx, namespaceKey{}, tt.namespace)
+			}
+			if tt.principal != "" {
+				ctx = context.WithValue(ctx, principalKey{}, tt.principal)
+			}
+
+			ev := &v1alpha1.Event{}
+			ev.Spec.Source = tt.source
+			ev.Spec.Type = tt.typ
+
+			router := newNamespaceRouter("default", tt.config)
+			ns, err := router.Route(ctx, ev)
+			if (err != nil) != tt.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err != nil && !errors.Is(err, errForbidden) {
+				t.Errorf("error = %v, want %v", err, errForbidden)
+			}
+			if ns != tt.want {
+				t.Errorf("namespace = %q, want %q", ns, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadRoutingConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		err    bool
+	}{
+		{
+			name: "valid",
+			config: `
+namespaces:
+- name: github
+rules:
+- namespace: github
+  matchSource: ^https://github\.com/
+`,
+		},
+		{
+			name: "namespace without name",
+			config: `
+namespaces:
+- principals: [token:ci]
+`,
+			err: true,
+		},
+		{
+			name: "rule for unknown namespace",
+			config: `
+namespaces:
+- name: github
+rules:
+- namespace: ci
+`,
+			err: true,
+		},
+		{
+			name: "invalid matchType",
+			config: `
+namespaces:
+- name: ci
+rules:
+- namespace: ci
+  matchType: "("
+`,
+			err: true,
+		},
+		{
+			name:   "invalid yaml",
+			config: `namespaces: {`,
+			err:    true,
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "routing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "routing.yaml")
+			if err := ioutil.WriteFile(path, []byte(tt.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := loadRoutingConfig(path)
+			if (err != nil) != tt.err {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1alpha1 "github.com/summerwind/eventreactor/api/v1alpha1"
+)
+
+// matchClusterSubscriptions returns the list of cluster subscriptions that
+// match the event. Matched cluster subscriptions are returned as
+// subscriptions in their target namespaces so that they are dispatched in
+// the same way as subscriptions.
+func (r *EventReconciler) matchClusterSubscriptions(ctx context.Context, ev *v1alpha1.Event, data interface{}, vars, attrs map[string]interface{}) ([]v1alpha1.Subscription, error) {
+	log := r.Log.WithValues("event", fmt.Sprintf("%s/%s", ev.Namespace, ev.Name))
+
+	candidates := []v1alpha1.ClusterSubscription{}
+	seen := map[string]bool{}
+
+	for _, key := range eventTypeKeys(ev.Spec.Type) {
+		var clusterSubscriptionList v1alpha1.ClusterSubscriptionList
+		err := r.List(ctx, &clusterSubscriptionList, client.MatchingFields{eventTypeKey: key})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, csub := range clusterSubscriptionList.Items {
+			if seen[csub.Name] {
+				continue
+			}
+			seen[csub.Name] = true
+			candidates = append(candidates, csub)
+		}
+	}
+
+	subs := []v1alpha1.Subscription{}
+	if len(candidates) == 0 {
+		return subs, nil
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Name < candidates[j].Name
+	})
+
+	// The namespace of event is fetched only once the first ready
+	// candidate is found.
+	var (
+		ns    *corev1.Namespace
+		nsErr error
+	)
+
+	for i := range candidates {
+		csub := &candidates[i]
+		subLog := log.WithValues("clustersubscription", csub.Name)
+
+		if csub.Status.ObservedGeneration != csub.Generation {
+			subLog.Info("ClusterSubscription has not been validated yet")
+			return nil, errSubscriptionPending
+		}
+
+		// Invalid cluster subscriptions are not dispatched.
+		if !csub.Status.IsReady(csub.Generation) {
+			subLog.Info("ClusterSubscription is not ready")
+			continue
+		}
+
+		if ns == nil && nsErr == nil {
+			ns = &corev1.Namespace{}
+			nsErr = r.Get(ctx, types.NamespacedName{Name: ev.Namespace}, ns)
+		}
+		if nsErr != nil {
+			subLog.Info("Failed to get event namespace", "error", nsErr.Error())
+			continue
+		}
+
+		matched, err := matchNamespace(csub.Spec.NamespaceSelector, ns)
+		if err != nil {
+			subLog.Info("Invalid namespace selector", "error", err.Error())
+			continue
+		}
+		if !matched {
+			subLog.V(1).Info("Event namespace mismatched")
+			continue
+		}
+
+		if !matchTrigger(&csub.Spec.Trigger, ev, vars, attrs, subLog) {
+			continue
+		}
+
+		target, err := r.targetNamespace(ctx, csub, ev, data)
+		if err != nil {
+			subLog.Info("Target namespace is not allowed", "error", err.Error())
+			continue
+		}
+
+		subs = append(subs, toSubscription(csub, target))
+	}
+
+	return subs, nil
+}
+
+// targetNamespace returns the namespace in which the resources of cluster
+// subscription are created. An error is returned if the namespace does not
+// match the target namespace selector.
+func (r *EventReconciler) targetNamespace(ctx context.Context, csub *v1alpha1.ClusterSubscription, ev *v1alpha1.Event, data interface{}) (string, error) {
+	name := ev.Namespace
+	if csub.Spec.TargetNamespace != "" {
+		var err error
+		name, err = expandName(csub.Spec.TargetNamespace, ev, data, csub.Spec.Parameters)
+		if err != nil {
+			return "", fmt.Errorf("failed to expand target namespace: %s", err)
+		}
+	}
+
+	if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+		return "", fmt.Errorf("invalid target namespace '%s': %s", name, errs[0])
+	}
+
+	var ns corev1.Namespace
+	err := r.Get(ctx, types.NamespacedName{Name: name}, &ns)
+	if err != nil {
+		return "", err
+	}
+
+	matched, err := matchNamespace(csub.Spec.TargetNamespaceSelector, &ns)
+	if err != nil {
+		return "", fmt.Errorf("invalid target namespace selector: %s", err)
+	}
+	if !matched {
+		return "", fmt.Errorf("namespace %s does not match target namespace selector", name)
+	}
+
+	return name, nil
+}
+
+// matchNamespace returns true if the labels of namespace match the
+// selector. Nil selector matches nothing.
+func matchNamespace(selector *metav1.LabelSelector, ns *corev1.Namespace) (bool, error) {
+	if selector == nil {
+		return false, nil
+	}
+
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false, err
+	}
+
+	return s.Matches(labels.Set(ns.Labels)), nil
+}
+
+// toSubscription returns a subscription that has the spec of the cluster
+// subscription in the target namespace. The kind of subscription is set to
+// ClusterSubscription to distinguish it from subscriptions.
+func toSubscription(csub *v1alpha1.ClusterSubscription, namespace string) v1alpha1.Subscription {
+	sub := v1alpha1.Subscription{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: v1alpha1.GroupVersion.String(),
+			Kind:       v1alpha1.ClusterSubscriptionKind,
+		},
+		ObjectMeta: *csub.ObjectMeta.DeepCopy(),
+		Spec:       *csub.Spec.SubscriptionSpec.DeepCopy(),
+		Status:     *csub.Status.DeepCopy(),
+	}
+	sub.Namespace = namespace
+
+	return sub
+}
+
+// subscriptionKind returns the kind of subscription.
+func subscriptionKind(sub *v1alpha1.Subscription) string {
+	if sub.Kind == v1alpha1.ClusterSubscriptionKind {
+		return v1alpha1.ClusterSubscriptionKind
+	}
+	return "Subscription"
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	v1alpha1 "github.com/summerwind/eventreactor/api/v1alpha1"
+)
+
+// namespaceCountingClient counts the number of namespaces fetched.
+type namespaceCountingClient struct {
+	client.Client
+	gets int
+}
+
+func (c *namespaceCountingClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	if _, ok := obj.(*corev1.Namespace); ok {
+		c.gets++
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+func TestMatchClusterSubscriptions(t *testing.T) {
+	selector := &metav1.LabelSelector{}
+	newClusterSub := func(name string, generation int64, ready bool) runtime.Object {
+		csub := &v1alpha1.ClusterSubscription{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Generation: generation},
+			Spec: v1alpha1.ClusterSubscriptionSpec{
+				SubscriptionSpec: v1alpha1.SubscriptionSpec{
+					Trigger: v1alpha1.SubscriptionSpecTrigger{Type: "test.*"},
+				},
+				NamespaceSelector:       selector,
+				TargetNamespaceSelector: selector,
+			},
+			Status: v1alpha1.SubscriptionStatus{ObservedGeneration: 1},
+		}
+		if ready {
+			csub.Status.SetCondition(v1alpha1.SubscriptionReady, corev1.ConditionTrue, "", "")
+		}
+		return csub
+	}
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+
+	tests := []struct {
+		name string
+		objs []runtime.Object
+		want []string
+		gets int
+		err  error
+	}{
+		{
+			name: "ready",
+			objs: []runtime.Object{ns, newClusterSub("a", 1, true), newClusterSub("b", 1, true)},
+			want: []string{"a", "b"},
+			gets: 3,
+		},
+		{
+			name: "not ready",
+			objs: []runtime.Object{ns, newClusterSub("a", 1, false), newClusterSub("b", 1, true)},
+			want: []string{"b"},
+			gets: 2,
+		},
+		{
+			name: "stale generation",
+			objs: []runtime.Object{ns, newClusterSub("a", 2, true)},
+			gets: 0,
+			err:  errSubscriptionPending,
+		},
+		{
+			name: "no candidates",
+			objs: []runtime.Object{ns},
+			want: []string{},
+			gets: 0,
+		},
+		{
+			name: "missing namespace",
+			objs: []runtime.Object{newClusterSub("a", 1, true), newClusterSub("b", 1, true)},
+			want: []string{},
+			gets: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &namespaceCountingClient{
+				Client: fake.NewFakeClientWithScheme(newTestScheme(t), tt.objs...),
+			}
+			r := &EventReconciler{Client: c, Log: log.NullLogger{}}
+			ev := &v1alpha1.Event{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "ev"},
+				Spec:       v1alpha1.EventSpec{Type: "test.event"},
+			}
+
+			subs, err := r.matchClusterSubscriptions(context.Background(), ev, nil, filterVariables(ev, nil), eventAttributes(ev))
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if c.gets != tt.gets {
+				t.Errorf("got %d namespace gets, want %d", c.gets, tt.gets)
+			}
+			if err != nil {
+				return
+			}
+
+			got := []string{}
+			for _, sub := range subs {
+				if sub.Namespace != "default" {
+					t.Errorf("%s: got namespace %s, want default", sub.Name, sub.Namespace)
+				}
+				got = append(got, sub.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	v1alpha1 "github.com/summerwind/eventreactor/api/v1alpha1"
+)
+
+const (
+	reasonInvalidNamespaceSelector       = "InvalidNamespaceSelector"
+	reasonInvalidTargetNamespace         = "InvalidTargetNamespace"
+	reasonInvalidTargetNamespaceSelector = "InvalidTargetNamespaceSelector"
+)
+
+// ClusterSubscriptionReconciler reconciles a ClusterSubscription object
+type ClusterSubscriptionReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+
+	mapper meta.RESTMapper
+}
+
+// +kubebuilder:rbac:groups=eventreactor.summerwind.dev,resources=clustersubscriptions,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=eventreactor.summerwind.dev,resources=clustersubscriptions/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=eventreactor.summerwind.dev,resources=resourcetemplates,verbs=get;list;watch
+
+func (r *ClusterSubscriptionReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("clustersubscription", req.Name)
+
+	var instance v1alpha1.ClusterSubscription
+	err := r.Get(ctx, req.NamespacedName, &instance)
+	if err != nil {
+		log.Error(err, "Failed to get cluster subscription")
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	generation, reason, message, err := r.validate(ctx, &instance)
+	if err != nil {
+		log.Error(err, "Failed to validate cluster subscription")
+		return ctrl.Result{}, err
+	}
+
+	result := ctrl.Result{}
+	if reason == reasonUnknownResourceKind {
+		// The CRD of resource may be installed after the subscription.
+		result.RequeueAfter = unknownKindRequeueInterval
+	}
+
+	csub := instance.DeepCopy()
+	csub.Status.ObservedGeneration = instance.Generation
+	csub.Status.TemplateGeneration = generation
+
+	if reason == reasonValid {
+		csub.Status.SetCondition(v1alpha1.SubscriptionReady, corev1.ConditionTrue, reason, message)
+		csub.Status.SetCondition(v1alpha1.SubscriptionInvalid, corev1.ConditionFalse, reason, message)
+	} else {
+		log.Info("Invalid cluster subscription", "reason", reason, "message", message)
+		csub.Status.SetCondition(v1alpha1.SubscriptionReady, corev1.ConditionFalse, reason, message)
+		csub.Status.SetCondition(v1alpha1.SubscriptionInvalid, corev1.ConditionTrue, reason, message)
+	}
+
+	if equality.Semantic.DeepEqual(instance.Status, csub.Status) {
+		return result, nil
+	}
+
+	err = r.Status().Update(ctx, csub)
+	if err != nil {
+		log.Error(err, "Failed to update cluster subscription")
+		return ctrl.Result{}, err
+	}
+
+	return result, nil
+}
+
+// validate validates the spec of cluster subscription resolved with the
+// referenced resource template. It also returns the generation of the
+// resource template.
+func (r *ClusterSubscriptionReconciler) validate(ctx context.Context, csub *v1alpha1.ClusterSubscription) (int64, string, string, error) {
+	// The spec is resolved with the referenced resource template, so
+	// validate a copy of it.
+	spec := csub.Spec.DeepCopy()
+
+	generation, reason, message, err := validateTemplateRef(ctx, r, &spec.SubscriptionSpec, "")
+	if err != nil || reason != reasonValid {
+		return generation, reason, message, err
+	}
+
+	reason, message = validationResult(v1alpha1.ValidateClusterSubscriptionSpec(spec, field.NewPath("spec")))
+	if reason != reasonValid {
+		return generation, reason, message, nil
+	}
+
+	reason, message, err = validateResourceKinds(spec.ResourceTemplates, r.mapper)
+	if err != nil || reason != reasonValid {
+		return generation, reason, message, err
+	}
+
+	return generation, reasonValid, "Cluster subscription is valid", nil
+}
+
+func (r *ClusterSubscriptionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.mapper = mgr.GetRESTMapper()
+
+	err := mgr.GetFieldIndexer().IndexField(&v1alpha1.ClusterSubscription{}, templateRefKey, func(obj runtime.Object) []string {
+		csub := obj.(*v1alpha1.ClusterSubscription)
+		if csub.Spec.TemplateRef == nil {
+			return nil
+		}
+		name, err := templateRefName(csub.Spec.TemplateRef, "")
+		if err != nil {
+			return nil
+		}
+		return []string{name.String()}
+	})
+	if err != nil {
+		return err
+	}
+
+	// Reconcile all cluster subscriptions that refer to the resource
+	// template when it has been changed.
+	mapper := handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+		name := types.NamespacedName{Namespace: obj.Meta.GetNamespace(), Name: obj.Meta.GetName()}
+
+		var csubList v1alpha1.ClusterSubscriptionList
+		err := r.List(context.Background(), &csubList, client.MatchingFields{templateRefKey: name.String()})
+		if err != nil {
+			r.Log.Error(err, "Failed to get cluster subscription list", "resourcetemplate", name)
+			return nil
+		}
+
+		reqs := make([]reconcile.Request, len(csubList.Items))
+		for i, csub := range csubList.Items {
+			reqs[i] = reconcile.Request{NamespacedName: types.NamespacedName{Name: csub.Name}}
+		}
+
+		return reqs
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.ClusterSubscription{}).
+		Watches(&source.Kind{Type: &v1alpha1.ResourceTemplate{}}, &handler.EnqueueRequestsFromMapFunc{ToRequests: mapper}).
+		Complete(r)
+}
@@ -0,0 +1,247 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	v1alpha1 "github.com/summerwind/eventreactor/api/v1alpha1"
+)
+
+// errNoDataStore is returned if the data store of event payload is not
+// configured.
+var errNoDataStore = errors.New("data store is not configured")
+
+// loadData returns a copy of the event whose payload is loaded from the
+// data store if the event has a data reference. Otherwise, the event is
+// returned as is. A permanent error is returned if the data reference is
+// invalid.
+func (r *EventReconciler) loadData(ctx context.Context, ev *v1alpha1.Event) (*v1alpha1.Event, error) {
+	ref := ev.Spec.DataRef
+	if ref == nil {
+		return ev, nil
+	}
+
+	// The reference is validated again since the webhook may be disabled.
+	if errs := v1alpha1.ValidateDataReference(ev); len(errs) > 0 {
+		return nil, permanent(fmt.Errorf("invalid data reference: %s", errs.ToAggregate()))
+	}
+
+	store, ok := r.DataStores[ref.Store]
+	if !ok {
+		return nil, errNoDataStore
+	}
+
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = ev.Namespace
+	}
+
+	data, err := store.Get(ctx, namespace, ref.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := ev.DeepCopy()
+	loaded.Spec.DataRef = nil
+	if utf8.Valid(data) {
+		loaded.Spec.Data = string(data)
+	} else {
+		loaded.Spec.DataBase64 = base64.StdEncoding.EncodeToString(data)
+	}
+
+	return loaded, nil
+}
+
+// decodeData decodes the payload of the event according to its content
+// type. JSON, XML and form encoded payloads are decoded into maps, text
+// and unknown payloads are returned as is. Payloads without the content
+// type are decoded as JSON if they are valid JSON. Binary payloads in
+// DataBase64 are returned as base64 encoded string unless its content type
+// is known.
+func decodeData(spec *v1alpha1.EventSpec) (interface{}, error) {
+	data := []byte(spec.Data)
+	if spec.DataBase64 != "" {
+		b, err := base64.StdEncoding.DecodeString(spec.DataBase64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 data: %s", err)
+		}
+		data = b
+	}
+
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	// Payloads without the content type are decoded as JSON if possible
+	// since CloudEvents JSON format implies application/json. Otherwise,
+	// they are treated as text so that the event is still dispatched.
+	if spec.DataContentType == "" {
+		if v, err := decodeJSON(data); err == nil {
+			return v, nil
+		}
+		if spec.DataBase64 != "" {
+			return spec.DataBase64, nil
+		}
+		return spec.Data, nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(spec.DataContentType)
+	if err != nil {
+		return nil, fmt.Errorf("invalid content type: %s", err)
+	}
+
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return decodeJSON(data)
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return decodeXML(data)
+	case mediaType == "application/x-www-form-urlencoded":
+		return decodeForm(string(data))
+	case strings.HasPrefix(mediaType, "text/"):
+		return string(data), nil
+	}
+
+	if spec.DataBase64 != "" {
+		return spec.DataBase64, nil
+	}
+
+	return spec.Data, nil
+}
+
+func decodeJSON(data []byte) (interface{}, error) {
+	var v interface{}
+
+	// Keep numbers as json.Number so that large integers such as IDs are
+	// rendered as is in templates.
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("invalid JSON data: %s", err)
+	}
+	if dec.More() {
+		return nil, errors.New("invalid JSON data: unexpected data after top-level value")
+	}
+
+	return v, nil
+}
+
+func decodeForm(data string) (interface{}, error) {
+	values, err := url.ParseQuery(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid form data: %s", err)
+	}
+
+	form := map[string]interface{}{}
+	for key, v := range values {
+		if len(v) == 1 {
+			form[key] = v[0]
+			continue
+		}
+
+		list := make([]interface{}, len(v))
+		for i := range v {
+			list[i] = v[i]
+		}
+		form[key] = list
+	}
+
+	return form, nil
+}
+
+// decodeXML decodes XML document into a map keyed by the name of root
+// element. Attributes are stored with '-' prefix and the text of element
+// that has attributes or child elements is stored as '#text'. Repeated
+// elements are stored as a list.
+func decodeXML(data []byte) (interface{}, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil, errors.New("invalid XML data: root element not found")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid XML data: %s", err)
+		}
+
+		if start, ok := tok.(xml.StartElement); ok {
+			v, err := decodeXMLElement(dec, start)
+			if err != nil {
+				return nil, fmt.Errorf("invalid XML data: %s", err)
+			}
+
+			return map[string]interface{}{start.Name.Local: v}, nil
+		}
+	}
+}
+
+func decodeXMLElement(dec *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	elem := map[string]interface{}{}
+	for _, attr := range start.Attr {
+		elem["-"+attr.Name.Local] = attr.Value
+	}
+
+	text := bytes.NewBuffer([]byte{})
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			child, err := decodeXMLElement(dec, t)
+			if err != nil {
+				return nil, err
+			}
+
+			name := t.Name.Local
+			switch current := elem[name].(type) {
+			case nil:
+				elem[name] = child
+			case []interface{}:
+				elem[name] = append(current, child)
+			default:
+				elem[name] = []interface{}{current, child}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			s := strings.TrimSpace(text.String())
+			if len(elem) == 0 {
+				return s, nil
+			}
+			if s != "" {
+				elem["#text"] = s
+			}
+			return elem, nil
+		}
+	}
+}
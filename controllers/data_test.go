@@ -0,0 +1,286 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	v1alpha1 "github.com/summerwind/eventreactor/api/v1alpha1"
+	"github.com/summerwind/eventreactor/pkg/blob"
+)
+
+func TestDecodeData(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    v1alpha1.EventSpec
+		want    interface{}
+		wantErr bool
+	}{
+		{
+			name: "empty",
+			spec: v1alpha1.EventSpec{},
+			want: nil,
+		},
+		{
+			name: "json without content type",
+			spec: v1alpha1.EventSpec{Data: `{"id":12345678901234567891,"name":"test"}`},
+			want: map[string]interface{}{
+				"id":   json.Number("12345678901234567891"),
+				"name": "test",
+			},
+		},
+		{
+			name: "text without content type",
+			spec: v1alpha1.EventSpec{Data: "hello, world"},
+			want: "hello, world",
+		},
+		{
+			name: "binary without content type",
+			spec: v1alpha1.EventSpec{DataBase64: "AAEC"},
+			want: "AAEC",
+		},
+		{
+			name: "json suffix",
+			spec: v1alpha1.EventSpec{DataContentType: "application/vnd.test+json; charset=utf-8", Data: `[1, "a"]`},
+			want: []interface{}{json.Number("1"), "a"},
+		},
+		{
+			name:    "invalid json",
+			spec:    v1alpha1.EventSpec{DataContentType: "application/json", Data: `{"id":`},
+			wantErr: true,
+		},
+		{
+			name:    "json with trailing data",
+			spec:    v1alpha1.EventSpec{DataContentType: "application/json", Data: `{} {}`},
+			wantErr: true,
+		},
+		{
+			name: "xml",
+			spec: v1alpha1.EventSpec{
+				DataContentType: "application/xml",
+				Data:            `<root id="1"><item>a</item><item>b</item><name>test</name>text</root>`,
+			},
+			want: map[string]interface{}{
+				"root": map[string]interface{}{
+					"-id":   "1",
+					"item":  []interface{}{"a", "b"},
+					"name":  "test",
+					"#text": "text",
+				},
+			},
+		},
+		{
+			name: "xml text only",
+			spec: v1alpha1.EventSpec{DataContentType: "text/xml", Data: `<?xml version="1.0"?><message> hello </message>`},
+			want: map[string]interface{}{"message": "hello"},
+		},
+		{
+			name:    "invalid xml",
+			spec:    v1alpha1.EventSpec{DataContentType: "application/xml", Data: `<root>`},
+			wantErr: true,
+		},
+		{
+			name:    "xml without root element",
+			spec:    v1alpha1.EventSpec{DataContentType: "application/xml", Data: `<?xml version="1.0"?>`},
+			wantErr: true,
+		},
+		{
+			name: "form",
+			spec: v1alpha1.EventSpec{DataContentType: "application/x-www-form-urlencoded", Data: "a=1&b=2&b=3"},
+			want: map[string]interface{}{
+				"a": "1",
+				"b": []interface{}{"2", "3"},
+			},
+		},
+		{
+			name:    "invalid form",
+			spec:    v1alpha1.EventSpec{DataContentType: "application/x-www-form-urlencoded", Data: "a=%zz"},
+			wantErr: true,
+		},
+		{
+			name: "text",
+			spec: v1alpha1.EventSpec{DataContentType: "text/plain", Data: "hello"},
+			want: "hello",
+		},
+		{
+			name: "base64 text",
+			spec: v1alpha1.EventSpec{DataContentType: "text/plain", DataBase64: "aGVsbG8="},
+			want: "hello",
+		},
+		{
+			name: "base64 json",
+			spec: v1alpha1.EventSpec{DataContentType: "application/json", DataBase64: "eyJhIjoiYiJ9"},
+			want: map[string]interface{}{"a": "b"},
+		},
+		{
+			name: "base64 binary",
+			spec: v1alpha1.EventSpec{DataContentType: "application/octet-stream", DataBase64: "AAEC"},
+			want: "AAEC",
+		},
+		{
+			name:    "invalid base64",
+			spec:    v1alpha1.EventSpec{DataContentType: "text/plain", DataBase64: "!"},
+			wantErr: true,
+		},
+		{
+			name: "unknown content type",
+			spec: v1alpha1.EventSpec{DataContentType: "application/octet-stream", Data: "raw"},
+			want: "raw",
+		},
+		{
+			name:    "invalid content type",
+			spec:    v1alpha1.EventSpec{DataContentType: "/", Data: "raw"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeData(&tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %#v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadData(t *testing.T) {
+	defer func(namespaces []string) {
+		v1alpha1.DeadLetterNamespaces = namespaces
+	}(v1alpha1.DeadLetterNamespaces)
+	v1alpha1.DeadLetterNamespaces = []string{"dead-letters"}
+
+	newConfigMap := func(namespace, name string, labels map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels},
+			Data:       map[string]string{"data": "payload"},
+		}
+	}
+
+	c := fake.NewFakeClientWithScheme(newTestScheme(t),
+		newConfigMap("default", "ev", map[string]string{v1alpha1.EventLabel: "ev"}),
+		newConfigMap("other", "ev", map[string]string{v1alpha1.EventLabel: "ev"}),
+		newConfigMap("default", "config", nil),
+	)
+	r := &EventReconciler{
+		Client: c,
+		Log:    log.NullLogger{},
+		DataStores: map[string]blob.Store{
+			v1alpha1.DataStoreConfigMap: blob.NewConfigMapStore(c),
+		},
+	}
+
+	tests := []struct {
+		name        string
+		namespace   string
+		annotations map[string]string
+		ref         v1alpha1.DataReference
+		want        string
+		err         error
+		permanent   bool
+	}{
+		{
+			name:      "event data",
+			namespace: "default",
+			ref:       v1alpha1.DataReference{Store: v1alpha1.DataStoreConfigMap, Name: "ev"},
+			want:      "payload",
+		},
+		{
+			name:      "without event label",
+			namespace: "default",
+			ref:       v1alpha1.DataReference{Store: v1alpha1.DataStoreConfigMap, Name: "config"},
+			err:       blob.ErrNotFound,
+		},
+		{
+			name:      "invalid name",
+			namespace: "default",
+			ref:       v1alpha1.DataReference{Store: v1alpha1.DataStoreConfigMap, Name: "../ev"},
+			permanent: true,
+		},
+		{
+			name:      "other namespace",
+			namespace: "default",
+			ref:       v1alpha1.DataReference{Store: v1alpha1.DataStoreConfigMap, Namespace: "other", Name: "ev"},
+			permanent: true,
+		},
+		{
+			name:        "dead letter",
+			namespace:   "dead-letters",
+			annotations: map[string]string{v1alpha1.DeadLetterAnnotation: "other/ev"},
+			ref:         v1alpha1.DataReference{Store: v1alpha1.DataStoreConfigMap, Namespace: "other", Name: "ev"},
+			want:        "payload",
+		},
+		{
+			name:        "dead letter of another namespace",
+			namespace:   "dead-letters",
+			annotations: map[string]string{v1alpha1.DeadLetterAnnotation: "default/ev"},
+			ref:         v1alpha1.DataReference{Store: v1alpha1.DataStoreConfigMap, Namespace: "other", Name: "ev"},
+			permanent:   true,
+		},
+		{
+			name:        "dead letter outside of dead letter namespaces",
+			namespace:   "default",
+			annotations: map[string]string{v1alpha1.DeadLetterAnnotation: "other/ev"},
+			ref:         v1alpha1.DataReference{Store: v1alpha1.DataStoreConfigMap, Namespace: "other", Name: "ev"},
+			permanent:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref := tt.ref
+			ev := &v1alpha1.Event{
+				ObjectMeta: metav1.ObjectMeta{Namespace: tt.namespace, Name: "ev", Annotations: tt.annotations},
+				Spec:       v1alpha1.EventSpec{DataRef: &ref},
+			}
+
+			got, err := r.loadData(context.Background(), ev)
+			if tt.permanent {
+				if !isPermanent(err) {
+					t.Fatalf("got %v, want permanent error", err)
+				}
+				return
+			}
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if got.Spec.Data != tt.want || got.Spec.DataRef != nil {
+				t.Errorf("got %+v, want data %q", got.Spec, tt.want)
+			}
+		})
+	}
+}
@@ -20,34 +20,62 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"regexp"
-	"text/template"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
-	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	v1alpha1 "github.com/summerwind/eventreactor/api/v1alpha1"
+	"github.com/summerwind/eventreactor/pkg/blob"
+	"github.com/summerwind/eventreactor/pkg/template"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
+// eventTypeKey is the index key of the event types of subscriptions. Prefix
+// patterns are indexed as is, and events look up the index with the type
+// and the patterns that match the type.
 var eventTypeKey = ".spec.trigger.type"
 
+// subscriptionPendingInterval is the interval to requeue the event that
+// matches subscriptions that have not been validated after their changes.
+const subscriptionPendingInterval = 5 * time.Second
+
+// errSubscriptionPending is returned if a subscription that may match the
+// event has been changed but has not been validated yet.
+var errSubscriptionPending = errors.New("subscription has not been validated yet")
+
+// fieldManager is the name of field manager that is used to apply
+// generated resources.
+const fieldManager = "eventreactor"
+
 // EventReconciler reconciles a Event object
 type EventReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
+
+	// DataStores is the data stores of event payloads keyed by their
+	// types.
+	DataStores map[string]blob.Store
 }
 
 // +kubebuilder:rbac:groups=eventreactor.summerwind.dev,resources=events,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=eventreactor.summerwind.dev,resources=events/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=eventreactor.summerwind.dev,resources=clustersubscriptions,verbs=get;list;watch
+// +kubebuilder:rbac:groups=eventreactor.summerwind.dev,resources=resourcetemplates,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=configmaps;secrets,verbs=get
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
 
 func (r *EventReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
@@ -65,107 +93,253 @@ func (r *EventReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		return ctrl.Result{}, nil
 	}
 
-	opts := []client.ListOption{
-		client.InNamespace(instance.Namespace),
-		client.MatchingFields{eventTypeKey: instance.Spec.Type},
+	event := instance.DeepCopy()
+
+	// The event with the payload loaded from the data store is used only
+	// for templates so that the payload is not written to the event.
+	source, err := r.loadData(ctx, event)
+	if err != nil && err != blob.ErrNotFound && err != errNoDataStore && !isPermanent(err) {
+		log.Error(err, "Failed to load event data")
+		return ctrl.Result{}, err
 	}
 
-	var subscriptionList v1alpha1.SubscriptionList
-	err = r.List(ctx, &subscriptionList, opts...)
+	var data interface{}
+	if err == nil {
+		data, err = decodeData(&source.Spec)
+	}
+	if err != nil {
+		log.Info("Invalid event data", "error", err.Error())
+
+		now := metav1.Now()
+		event.Status.Phase = v1alpha1.EventPhaseFailed
+		event.Status.Reason = "InvalidData"
+		event.Status.Message = err.Error()
+		event.Status.DispatchTime = &now
+
+		err = r.Update(ctx, event)
+		if err != nil {
+			log.Error(err, "Failed to update event")
+			return ctrl.Result{}, err
+		}
+
+		return ctrl.Result{}, nil
+	}
+
+	subs, err := r.matchSubscriptions(ctx, event, data)
+	if err == errSubscriptionPending {
+		// The event is not dispatched until the changes of subscriptions
+		// are validated, so that it is not finalized without them.
+		log.Info("Waiting for subscriptions to be validated")
+		return ctrl.Result{RequeueAfter: subscriptionPendingInterval}, nil
+	}
 	if err != nil {
 		log.Error(err, "Failed to get subscription list")
 		return ctrl.Result{}, err
 	}
 
-	for _, sub := range subscriptionList.Items {
-		var (
-			err     error
-			matched bool
-		)
+	now := time.Now()
 
+	// Render all resources before applying them so that the generated
+	// names are recorded in the status before the resources are created.
+	results := make([]v1alpha1.SubscriptionResult, len(subs))
+	resources := make([][]*unstructured.Unstructured, len(subs))
+	dispatch := make([]bool, len(subs))
+	// Failures that are not resolved by retries, such as errors of
+	// rendering templates, are sent to the dead letter without retries.
+	permanentFailure := make([]bool, len(subs))
+
+	for i := range subs {
+		sub := &subs[i]
 		subLog := log.WithValues("subscription", fmt.Sprintf("%s/%s", sub.Namespace, sub.Name))
+		prev := findSubscriptionResult(event.Status.Subscriptions, subscriptionKind(sub), sub.Name)
+
+		if prev != nil {
+			// Skip the subscription that has been completed or is waiting
+			// for the next retry.
+			if prev.Phase == v1alpha1.EventPhaseSucceeded || prev.Phase == v1alpha1.EventPhaseFailed ||
+				(prev.NextRetryTime != nil && now.Before(prev.NextRetryTime.Time)) {
+				results[i] = *prev
+				continue
+			}
+		}
+
+		results[i] = v1alpha1.SubscriptionResult{
+			Kind:  subscriptionKind(sub),
+			Name:  sub.Name,
+			Phase: v1alpha1.EventPhaseDispatching,
+		}
+		if prev != nil {
+			results[i].Attempts = prev.Attempts
+		}
 
-		if sub.Spec.Trigger.Type != instance.Spec.Type {
-			subLog.V(1).Info("Event type mismatched")
+		dispatch[i] = true
+
+		// The referenced resource template is resolved at every attempt so
+		// that its changes are rolled out to the subscriptions.
+		namespace := sub.Namespace
+		if subscriptionKind(sub) == v1alpha1.ClusterSubscriptionKind {
+			namespace = ""
+		}
+		if err := resolveResourceTemplate(ctx, r, &sub.Spec, namespace); err != nil {
+			subLog.Error(err, "Failed to resolve resource template")
+			permanentFailure[i] = isPermanent(err)
+			resources[i] = []*unstructured.Unstructured{nil}
+			results[i].Resources = []v1alpha1.ResourceResult{
+				{Error: fmt.Sprintf("failed to resolve resource template: %s", err)},
+			}
 			continue
 		}
 
-		if sub.Spec.Trigger.MatchSource != "" {
-			matched, err = regexp.MatchString(sub.Spec.Trigger.MatchSource, instance.Spec.Source)
-			if err != nil {
-				subLog.Info("Invalid event source pattern")
+		resources[i] = make([]*unstructured.Unstructured, len(sub.Spec.ResourceTemplates))
+
+		for j := range sub.Spec.ResourceTemplates {
+			// Resources that have been applied by the previous attempt are
+			// not applied again.
+			prevResult := findResourceResult(prev, j)
+			if prevResult != nil && prevResult.Action != "" && prevResult.Error == "" {
+				results[i].Resources = append(results[i].Resources, *prevResult)
 				continue
 			}
-			if !matched {
-				subLog.V(1).Info("Event source mismatched")
-				continue
+
+			res, resResult, err := render(sub, j, source, data, prev)
+			if err != nil {
+				subLog.Error(err, "Failed to render resource", "index", j)
+				resResult.Error = err.Error()
+				permanentFailure[i] = true
 			}
+
+			resources[i][j] = res
+			results[i].Resources = append(results[i].Resources, resResult)
 		}
+	}
 
-		if sub.Spec.Trigger.MatchSubject != "" {
-			matched, err = regexp.MatchString(sub.Spec.Trigger.MatchSubject, instance.Spec.Subject)
-			if err != nil {
-				subLog.Info("Invalid event subject pattern")
+	event.Status.Phase = v1alpha1.EventPhaseDispatching
+	event.Status.Subscriptions = results
+
+	if !equality.Semantic.DeepEqual(instance.Status, event.Status) {
+		err = r.Update(ctx, event)
+		if err != nil {
+			log.Error(err, "Failed to update event")
+			return ctrl.Result{}, err
+		}
+	}
+
+	for i := range subs {
+		if !dispatch[i] {
+			continue
+		}
+
+		sub := &subs[i]
+		subLog := log.WithValues("subscription", fmt.Sprintf("%s/%s", sub.Namespace, sub.Name))
+		subResult := &results[i]
+
+		for j, res := range resources[i] {
+			if res == nil {
 				continue
 			}
-			if !matched {
-				subLog.Info("Event subject mismatched")
+
+			resLog := subLog.WithValues("kind", res.GetKind(), "name", fmt.Sprintf("%s/%s", res.GetNamespace(), res.GetName()))
+			resResult := &subResult.Resources[j]
+
+			action, err := r.apply(ctx, sub, res)
+			if err != nil {
+				resLog.Error(err, "Failed to apply resource")
+				resResult.Error = err.Error()
 				continue
 			}
+
+			resLog.Info("Resource applied", "action", action)
+			resResult.UID = res.GetUID()
+			resResult.Action = action
 		}
 
-		for _, tmpl := range sub.Spec.ResourceTemplates {
-			res := tmpl.DeepCopy()
-			res.SetNamespace(sub.Namespace)
-			if res.GetName() == "" {
-				res.SetName(sub.Name)
-			}
+		subResult.Attempts++
+		subResult.NextRetryTime = nil
 
-			resLog := subLog.WithValues("kind", res.GroupVersionKind().Kind, "name", fmt.Sprintf("%s/%s", res.GetNamespace(), res.GetName()))
+		failed := countFailedResources(subResult)
+		if failed == 0 {
+			subResult.Phase = v1alpha1.EventPhaseSucceeded
+			continue
+		}
 
-			err = expandVars(res, &instance)
-			if err != nil {
-				resLog.Error(err, "Failed to expand variables")
-			}
+		policy := retryPolicy(sub)
+		if !permanentFailure[i] && subResult.Attempts < *policy.MaxAttempts {
+			next := metav1.NewTime(now.Add(backoff(policy, subResult.Attempts)))
+			subResult.NextRetryTime = &next
+			subLog.Info("Dispatch failed, retry scheduled", "attempts", subResult.Attempts, "nextRetryTime", next)
+			continue
+		}
+
+		if permanentFailure[i] {
+			subLog.Info("Dispatch failed permanently", "attempts", subResult.Attempts)
+		} else {
+			subLog.Info("Dispatch failed, retries exhausted", "attempts", subResult.Attempts)
+		}
 
-			key := types.NamespacedName{
-				Name:      res.GetName(),
-				Namespace: res.GetNamespace(),
+		subResult.Phase = v1alpha1.EventPhaseFailed
+
+		err = r.deadLetter(ctx, sub, event, subResult)
+		if err != nil {
+			subLog.Error(err, "Failed to send event to dead letter")
+			return ctrl.Result{}, err
+		}
+	}
+
+	event.Status.Subscriptions = results
+
+	var (
+		retrying      int
+		failed        int
+		nextRetryTime *metav1.Time
+	)
+
+	for _, result := range results {
+		switch result.Phase {
+		case v1alpha1.EventPhaseFailed:
+			failed++
+		case v1alpha1.EventPhaseDispatching:
+			retrying++
+			if result.NextRetryTime != nil && (nextRetryTime == nil || result.NextRetryTime.Before(nextRetryTime)) {
+				nextRetryTime = result.NextRetryTime
 			}
+		}
+	}
 
-			current := unstructured.Unstructured{}
-			current.SetGroupVersionKind(res.GroupVersionKind())
+	if retrying > 0 {
+		event.Status.Phase = v1alpha1.EventPhaseDispatching
+		event.Status.Reason = "RetryScheduled"
+		event.Status.Message = fmt.Sprintf("Dispatch to %d subscription(s) will be retried", retrying)
 
-			err = r.Get(ctx, key, &current)
+		// Updating the event triggers another reconciliation, so the event
+		// is updated only if the status has been changed.
+		if !equality.Semantic.DeepEqual(instance.Status, event.Status) {
+			err = r.Update(ctx, event)
 			if err != nil {
-				if errors.IsNotFound(err) {
-					err = r.Create(ctx, res)
-					if err != nil {
-						resLog.Error(err, "Failed to create resource")
-						return reconcile.Result{}, err
-					}
-					resLog.Info("Resource created")
-				} else {
-					return ctrl.Result{}, err
-				}
-			} else {
-				// resourceVersion field must be keep to update custom resource.
-				// If it is not set, API will return a validation error.
-				res.Object["metadata"] = current.Object["metadata"]
-
-				err = r.Update(ctx, res)
-				if err != nil {
-					resLog.Error(err, "Failed to update resource")
-					return reconcile.Result{}, err
-				}
-				resLog.Info("Resource updated")
+				log.Error(err, "Failed to update event")
+				return ctrl.Result{}, err
 			}
 		}
+
+		result := ctrl.Result{Requeue: true}
+		if nextRetryTime != nil {
+			result.RequeueAfter = time.Until(nextRetryTime.Time)
+		}
+
+		return result, nil
 	}
 
-	now := metav1.Now()
-	event := instance.DeepCopy()
-	event.Status.DispatchTime = &now
+	dispatchTime := metav1.Now()
+	event.Status.DispatchTime = &dispatchTime
+
+	if failed > 0 {
+		event.Status.Phase = v1alpha1.EventPhaseFailed
+		event.Status.Reason = "DispatchFailed"
+		event.Status.Message = fmt.Sprintf("Failed to dispatch to %d subscription(s)", failed)
+	} else {
+		event.Status.Phase = v1alpha1.EventPhaseSucceeded
+		event.Status.Reason = "Dispatched"
+		event.Status.Message = fmt.Sprintf("Dispatched to %d subscription(s)", len(results))
+	}
 
 	err = r.Update(ctx, event)
 	if err != nil {
@@ -176,10 +350,373 @@ func (r *EventReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	return ctrl.Result{}, nil
 }
 
+// matchSubscriptions returns the list of subscriptions that match the event.
+// The data is the decoded payload of the event used by filters.
+func (r *EventReconciler) matchSubscriptions(ctx context.Context, ev *v1alpha1.Event, data interface{}) ([]v1alpha1.Subscription, error) {
+	log := r.Log.WithValues("event", fmt.Sprintf("%s/%s", ev.Namespace, ev.Name))
+
+	// Subscriptions are indexed by their event types and prefix patterns,
+	// so look up the index with all the patterns that match the type.
+	candidates := []v1alpha1.Subscription{}
+	seen := map[string]bool{}
+
+	for _, key := range eventTypeKeys(ev.Spec.Type) {
+		opts := []client.ListOption{
+			client.InNamespace(ev.Namespace),
+			client.MatchingFields{eventTypeKey: key},
+		}
+
+		var subscriptionList v1alpha1.SubscriptionList
+		err := r.List(ctx, &subscriptionList, opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, sub := range subscriptionList.Items {
+			if seen[sub.Name] {
+				continue
+			}
+			seen[sub.Name] = true
+			candidates = append(candidates, sub)
+		}
+	}
+
+	// Keep the order of subscriptions stable.
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Name < candidates[j].Name
+	})
+
+	subs := []v1alpha1.Subscription{}
+	vars := filterVariables(ev, data)
+	attrs := eventAttributes(ev)
+
+	for _, sub := range candidates {
+		subLog := log.WithValues("subscription", fmt.Sprintf("%s/%s", sub.Namespace, sub.Name))
+
+		if sub.Status.ObservedGeneration != sub.Generation {
+			subLog.Info("Subscription has not been validated yet")
+			return nil, errSubscriptionPending
+		}
+
+		// Invalid subscriptions are not dispatched.
+		if !sub.Status.IsReady(sub.Generation) {
+			subLog.Info("Subscription is not ready")
+			continue
+		}
+
+		if !matchTrigger(&sub.Spec.Trigger, ev, vars, attrs, subLog) {
+			continue
+		}
+
+		subs = append(subs, sub)
+	}
+
+	clusterSubs, err := r.matchClusterSubscriptions(ctx, ev, data, vars, attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(subs, clusterSubs...), nil
+}
+
+// matchTrigger returns true if the event matches the trigger of
+// subscription.
+func matchTrigger(trigger *v1alpha1.SubscriptionSpecTrigger, ev *v1alpha1.Event, vars, attrs map[string]interface{}, log logr.Logger) bool {
+	if !matchEventTypes(trigger.EventTypes(), ev.Spec.Type) {
+		log.V(1).Info("Event type mismatched")
+		return false
+	}
+
+	if trigger.MatchSource != "" {
+		matched, err := regexp.MatchString(trigger.MatchSource, ev.Spec.Source)
+		if err != nil {
+			log.Info("Invalid event source pattern")
+			return false
+		}
+		if !matched {
+			log.V(1).Info("Event source mismatched")
+			return false
+		}
+	}
+
+	if trigger.MatchSubject != "" {
+		matched, err := regexp.MatchString(trigger.MatchSubject, ev.Spec.Subject)
+		if err != nil {
+			log.Info("Invalid event subject pattern")
+			return false
+		}
+		if !matched {
+			log.Info("Event subject mismatched")
+			return false
+		}
+	}
+
+	if !matchExtensions(trigger.MatchExtensions, ev.Spec.Extensions, log) {
+		return false
+	}
+
+	if !matchFilters(trigger.Filters, vars, log) {
+		return false
+	}
+
+	if !matchEventFilters(trigger.MatchFilters, attrs, log) {
+		return false
+	}
+
+	return true
+}
+
+// matchEventTypes returns true if any of the types matches the event type.
+func matchEventTypes(types []string, eventType string) bool {
+	for _, t := range types {
+		if v1alpha1.MatchEventType(t, eventType) {
+			return true
+		}
+	}
+	return false
+}
+
+// eventTypeKeys returns the index keys of subscriptions that may match the
+// event type: the type itself, the prefix patterns at each dot boundary,
+// and '*'. For example, 'com.github.push' returns 'com.github.push',
+// 'com.github.*', 'com.*' and '*'.
+func eventTypeKeys(eventType string) []string {
+	keys := []string{eventType}
+	for i := len(eventType) - 1; i > 0; i-- {
+		if eventType[i] == '.' && i < len(eventType)-1 {
+			keys = append(keys, eventType[:i+1]+"*")
+		}
+	}
+	return append(keys, "*")
+}
+
+// matchExtensions returns true if all the extension attributes match the
+// patterns.
+func matchExtensions(patterns, extensions map[string]string, log logr.Logger) bool {
+	for name, pattern := range patterns {
+		value, ok := extensions[name]
+		if !ok {
+			log.V(1).Info("Event extension not found", "extension", name)
+			return false
+		}
+
+		matched, err := regexp.MatchString(pattern, value)
+		if err != nil {
+			log.Info("Invalid event extension pattern", "extension", name)
+			return false
+		}
+		if !matched {
+			log.V(1).Info("Event extension mismatched", "extension", name)
+			return false
+		}
+	}
+
+	return true
+}
+
+// filterVariables returns the variables of the event for filters.
+func filterVariables(ev *v1alpha1.Event, data interface{}) map[string]interface{} {
+	extensions := ev.Spec.Extensions
+	if extensions == nil {
+		extensions = map[string]string{}
+	}
+
+	t := ""
+	if ev.Spec.Time != nil {
+		t = ev.Spec.Time.UTC().Format(time.RFC3339)
+	}
+
+	return map[string]interface{}{
+		"id":              ev.Spec.ID,
+		"source":          ev.Spec.Source,
+		"type":            ev.Spec.Type,
+		"subject":         ev.Spec.Subject,
+		"time":            t,
+		"datacontenttype": ev.Spec.DataContentType,
+		"dataschema":      ev.Spec.DataSchema,
+		"extensions":      extensions,
+		"data":            data,
+	}
+}
+
+// matchFilters returns true if all the filters are evaluated to true.
+// Filters that fail to be evaluated, such as a reference to the missing
+// field of data, do not match.
+func matchFilters(filters []string, vars map[string]interface{}, log logr.Logger) bool {
+	for i, filter := range filters {
+		prog, err := compileFilter(filter)
+		if err != nil {
+			log.Info("Invalid filter", "index", i, "error", err.Error())
+			return false
+		}
+
+		matched, err := prog.EvalBool(vars)
+		if err != nil {
+			log.V(1).Info("Failed to evaluate filter", "index", i, "error", err.Error())
+			return false
+		}
+		if !matched {
+			log.V(1).Info("Event filter mismatched", "index", i)
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchEventFilters returns true if all the filters of CloudEvents
+// Subscriptions API match the attributes.
+func matchEventFilters(filters []v1alpha1.EventFilter, attrs map[string]interface{}, log logr.Logger) bool {
+	for i := range filters {
+		matched, err := matchEventFilter(&filters[i], attrs)
+		if err != nil {
+			log.Info("Invalid event filter", "index", i, "error", err.Error())
+			return false
+		}
+		if !matched {
+			log.V(1).Info("Event match filter mismatched", "index", i)
+			return false
+		}
+	}
+
+	return true
+}
+
+// apply applies the resource with the apply strategy of subscription. It
+// returns the action that was performed for the resource.
+func (r *EventReconciler) apply(ctx context.Context, sub *v1alpha1.Subscription, res *unstructured.Unstructured) (string, error) {
+	switch sub.Spec.ApplyStrategy {
+	case v1alpha1.ApplyStrategyCreate:
+		return r.create(ctx, res)
+	case v1alpha1.ApplyStrategyServerSideApply:
+		return r.serverSideApply(ctx, res)
+	case v1alpha1.ApplyStrategyPatch:
+		return r.patch(ctx, res, sub.Spec.PatchType)
+	}
+
+	return r.createOrUpdate(ctx, res)
+}
+
+// create creates the resource if it does not exist.
+func (r *EventReconciler) create(ctx context.Context, res *unstructured.Unstructured) (string, error) {
+	err := r.Create(ctx, res, client.FieldOwner(fieldManager))
+	if err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return "", err
+		}
+
+		key := types.NamespacedName{
+			Name:      res.GetName(),
+			Namespace: res.GetNamespace(),
+		}
+
+		err = r.Get(ctx, key, res)
+		if err != nil {
+			return "", err
+		}
+
+		return v1alpha1.ResourceActionSkipped, nil
+	}
+
+	return v1alpha1.ResourceActionCreated, nil
+}
+
+// createOrUpdate creates the resource or replaces the existing resource.
+func (r *EventReconciler) createOrUpdate(ctx context.Context, res *unstructured.Unstructured) (string, error) {
+	key := types.NamespacedName{
+		Name:      res.GetName(),
+		Namespace: res.GetNamespace(),
+	}
+
+	current := unstructured.Unstructured{}
+	current.SetGroupVersionKind(res.GroupVersionKind())
+
+	err := r.Get(ctx, key, &current)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return "", err
+		}
+
+		err = r.Create(ctx, res, client.FieldOwner(fieldManager))
+		if err != nil {
+			return "", err
+		}
+
+		return v1alpha1.ResourceActionCreated, nil
+	}
+
+	labels := res.GetLabels()
+	annotations := res.GetAnnotations()
+	ownerRefs := res.GetOwnerReferences()
+
+	// resourceVersion field must be keep to update custom resource.
+	// If it is not set, API will return a validation error.
+	res.Object["metadata"] = current.Object["metadata"]
+	res.SetLabels(mergeMap(current.GetLabels(), labels))
+	res.SetAnnotations(mergeMap(current.GetAnnotations(), annotations))
+	res.SetOwnerReferences(mergeOwnerReferences(current.GetOwnerReferences(), ownerRefs))
+
+	err = r.Update(ctx, res, client.FieldOwner(fieldManager))
+	if err != nil {
+		return "", err
+	}
+
+	return v1alpha1.ResourceActionUpdated, nil
+}
+
+// serverSideApply applies the resource with server-side apply. The fields
+// in the resource are owned by the field manager of eventreactor.
+func (r *EventReconciler) serverSideApply(ctx context.Context, res *unstructured.Unstructured) (string, error) {
+	err := r.Patch(ctx, res, client.Apply, client.FieldOwner(fieldManager), client.ForceOwnership)
+	if err != nil {
+		return "", err
+	}
+
+	return v1alpha1.ResourceActionApplied, nil
+}
+
+// patch patches the existing resource or creates the resource if it does
+// not exist.
+func (r *EventReconciler) patch(ctx context.Context, res *unstructured.Unstructured, patchType v1alpha1.PatchType) (string, error) {
+	pt := types.MergePatchType
+	if patchType == v1alpha1.PatchTypeStrategicMerge {
+		pt = types.StrategicMergePatchType
+	}
+
+	data, err := json.Marshal(res.Object)
+	if err != nil {
+		return "", err
+	}
+
+	err = r.Patch(ctx, res, client.ConstantPatch(pt, data), client.FieldOwner(fieldManager))
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return "", err
+		}
+
+		err = r.Create(ctx, res, client.FieldOwner(fieldManager))
+		if err != nil {
+			return "", err
+		}
+
+		return v1alpha1.ResourceActionCreated, nil
+	}
+
+	return v1alpha1.ResourceActionUpdated, nil
+}
+
 func (r *EventReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	err := mgr.GetFieldIndexer().IndexField(&v1alpha1.Subscription{}, eventTypeKey, func(obj runtime.Object) []string {
 		sub := obj.(*v1alpha1.Subscription)
-		return []string{sub.Spec.Trigger.Type}
+		return sub.Spec.Trigger.EventTypes()
+	})
+	if err != nil {
+		return err
+	}
+
+	err = mgr.GetFieldIndexer().IndexField(&v1alpha1.ClusterSubscription{}, eventTypeKey, func(obj runtime.Object) []string {
+		sub := obj.(*v1alpha1.ClusterSubscription)
+		return sub.Spec.Trigger.EventTypes()
 	})
 	if err != nil {
 		return err
@@ -190,37 +727,67 @@ func (r *EventReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Complete(r)
 }
 
-func expandVars(res *unstructured.Unstructured, ev *v1alpha1.Event) error {
-	content := res.UnstructuredContent()
-
-	resBytes, err := json.Marshal(content)
+// expandVars expands the variables in the resource. Each string in the
+// resource is expanded separately so that the values of event cannot
+// change the structure of the resource.
+func expandVars(res *unstructured.Unstructured, ev *v1alpha1.Event, data interface{}, params map[string]string) error {
+	content, err := template.ExecuteObject(res.UnstructuredContent(), newVars(ev, data, params))
 	if err != nil {
 		return err
 	}
 
-	tmpl, err := template.New("resource").Delims("((", "))").Parse(string(resBytes))
+	res.SetUnstructuredContent(content)
+
+	return nil
+}
+
+// expandName expands the variables in the name template.
+func expandName(text string, ev *v1alpha1.Event, data interface{}, params map[string]string) (string, error) {
+	tmpl, err := template.New("name").Parse(text)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	vars := struct {
-		Event *v1alpha1.Event
-		Data  interface{}
-	}{
-		Event: ev,
-		Data:  nil,
+	buf := bytes.NewBuffer([]byte{})
+	if err := tmpl.Execute(buf, newVars(ev, data, params)); err != nil {
+		return "", err
 	}
 
-	buf := bytes.NewBuffer([]byte{})
-	if err := tmpl.Execute(buf, vars); err != nil {
-		return err
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// newVars returns the variables that are available in templates. The
+// parameters of subscription are available as Params.
+func newVars(ev *v1alpha1.Event, data interface{}, params map[string]string) interface{} {
+	if params == nil {
+		params = map[string]string{}
 	}
 
-	if err := json.Unmarshal(buf.Bytes(), &content); err != nil {
-		return err
+	return struct {
+		Event  *v1alpha1.Event
+		Data   interface{}
+		Params map[string]string
+	}{
+		Event:  ev,
+		Data:   data,
+		Params: params,
 	}
+}
 
-	res.SetUnstructuredContent(content)
+// mergeMap returns a new map that contains the keys of both maps. The
+// value of src overrides the value of dst.
+func mergeMap(dst, src map[string]string) map[string]string {
+	if len(dst) == 0 && len(src) == 0 {
+		return nil
+	}
 
-	return nil
+	m := map[string]string{}
+	for k, v := range dst {
+		m[k] = v
+	}
+	for k, v := range src {
+		m[k] = v
+	}
+
+	return m
 }
@@ -0,0 +1,177 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	v1alpha1 "github.com/summerwind/eventreactor/api/v1alpha1"
+)
+
+func TestEventTypeKeys(t *testing.T) {
+	tests := []struct {
+		eventType string
+		keys      []string
+	}{
+		{"com.github.push", []string{"com.github.push", "com.github.*", "com.*", "*"}},
+		{"push", []string{"push", "*"}},
+		{"a.b", []string{"a.b", "a.*", "*"}},
+		{"a..b", []string{"a..b", "a..*", "a.*", "*"}},
+		{"a.b.", []string{"a.b.", "a.*", "*"}},
+		{".a", []string{".a", "*"}},
+		{"", []string{"", "*"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.eventType, func(t *testing.T) {
+			keys := eventTypeKeys(tt.eventType)
+			if !reflect.DeepEqual(keys, tt.keys) {
+				t.Errorf("eventTypeKeys() = %v, want %v", keys, tt.keys)
+			}
+		})
+	}
+}
+
+// TestEventTypeKeysCoverPatterns ensures that the subscriptions with the
+// patterns that match the event type are found by the index keys.
+func TestEventTypeKeysCoverPatterns(t *testing.T) {
+	types := []string{"com.github.push", "com.github", "com", "a..b", "a.b.", "x.y.z.w"}
+	patterns := []string{
+		"*", "com.*", "com.github.*", "com.github.push.*", "com.github.push",
+		"a.*", "a..*", "a.b.*", "x.*", "x.y.*", "x.y.z.*", "x.y.z.w",
+	}
+
+	for _, eventType := range types {
+		keys := map[string]bool{}
+		for _, key := range eventTypeKeys(eventType) {
+			keys[key] = true
+		}
+
+		for _, pattern := range patterns {
+			if v1alpha1.MatchEventType(pattern, eventType) != keys[pattern] {
+				t.Errorf("%s: pattern %s matches %v, but key exists %v", eventType, pattern, v1alpha1.MatchEventType(pattern, eventType), keys[pattern])
+			}
+		}
+	}
+}
+
+func TestMatchEventTypes(t *testing.T) {
+	tests := []struct {
+		types     []string
+		eventType string
+		want      bool
+	}{
+		{[]string{"com.github.push"}, "com.github.push", true},
+		{[]string{"com.github.pull_request", "com.github.push"}, "com.github.push", true},
+		{[]string{"com.github.*"}, "com.github.push", true},
+		{[]string{"com.github.*"}, "com.github.", false},
+		{[]string{"com.github.*"}, "com.github", false},
+		{[]string{"com.*"}, "com.github.push", true},
+		{[]string{"*"}, "com.github.push", true},
+		{[]string{"com.github"}, "com.github.push", false},
+		{[]string{}, "com.github.push", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchEventTypes(tt.types, tt.eventType); got != tt.want {
+			t.Errorf("matchEventTypes(%v, %s) = %v, want %v", tt.types, tt.eventType, got, tt.want)
+		}
+	}
+}
+
+func TestMatchSubscriptions(t *testing.T) {
+	newSub := func(name string, generation, observed int64, ready bool) runtime.Object {
+		sub := &v1alpha1.Subscription{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Generation: generation},
+			Spec: v1alpha1.SubscriptionSpec{
+				Trigger: v1alpha1.SubscriptionSpecTrigger{Type: "test.*"},
+			},
+			Status: v1alpha1.SubscriptionStatus{ObservedGeneration: observed},
+		}
+		status := corev1.ConditionFalse
+		if ready {
+			status = corev1.ConditionTrue
+		}
+		sub.Status.SetCondition(v1alpha1.SubscriptionReady, status, "", "")
+		return sub
+	}
+
+	tests := []struct {
+		name string
+		objs []runtime.Object
+		want []string
+		err  error
+	}{
+		{
+			name: "ready",
+			objs: []runtime.Object{newSub("a", 1, 1, true), newSub("b", 2, 2, true)},
+			want: []string{"a", "b"},
+		},
+		{
+			name: "invalid",
+			objs: []runtime.Object{newSub("a", 1, 1, false), newSub("b", 1, 1, true)},
+			want: []string{"b"},
+		},
+		{
+			name: "not validated",
+			objs: []runtime.Object{newSub("a", 2, 1, true), newSub("b", 1, 1, true)},
+			err:  errSubscriptionPending,
+		},
+		{
+			name: "new",
+			objs: []runtime.Object{newSub("a", 1, 0, false)},
+			err:  errSubscriptionPending,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &EventReconciler{
+				Client: fake.NewFakeClientWithScheme(newTestScheme(t), tt.objs...),
+				Log:    log.NullLogger{},
+			}
+			ev := &v1alpha1.Event{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "ev"},
+				Spec:       v1alpha1.EventSpec{Type: "test.event"},
+			}
+
+			subs, err := r.matchSubscriptions(context.Background(), ev, nil)
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+
+			got := []string{}
+			for _, sub := range subs {
+				got = append(got, sub.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	v1alpha1 "github.com/summerwind/eventreactor/api/v1alpha1"
+)
+
+// eventAttributes returns the context attributes and the extension
+// attributes of the event for filters of CloudEvents Subscriptions API.
+// Optional attributes that the event does not have are omitted.
+func eventAttributes(ev *v1alpha1.Event) map[string]interface{} {
+	attrs := map[string]interface{}{}
+	for name, value := range ev.Spec.Extensions {
+		attrs[name] = value
+	}
+
+	attrs["specversion"] = v1alpha1.SpecVersion10
+	if ev.Spec.SpecVersion != "" {
+		attrs["specversion"] = ev.Spec.SpecVersion
+	}
+	attrs["id"] = ev.Spec.ID
+	attrs["source"] = ev.Spec.Source
+	attrs["type"] = ev.Spec.Type
+
+	if ev.Spec.Subject != "" {
+		attrs["subject"] = ev.Spec.Subject
+	}
+	if ev.Spec.Time != nil {
+		attrs["time"] = ev.Spec.Time.UTC().Format(time.RFC3339)
+	}
+	if ev.Spec.DataContentType != "" {
+		attrs["datacontenttype"] = ev.Spec.DataContentType
+	}
+	if ev.Spec.DataSchema != "" {
+		attrs["dataschema"] = ev.Spec.DataSchema
+	}
+
+	return attrs
+}
+
+// matchEventFilter returns true if the attributes match the filter. An
+// error is returned if the filter is invalid.
+func matchEventFilter(filter *v1alpha1.EventFilter, attrs map[string]interface{}) (bool, error) {
+	switch {
+	case filter.Exact != nil:
+		return matchAttribute(filter.Exact, attrs, func(v, s string) bool { return v == s })
+
+	case filter.Prefix != nil:
+		return matchAttribute(filter.Prefix, attrs, strings.HasPrefix)
+
+	case filter.Suffix != nil:
+		return matchAttribute(filter.Suffix, attrs, strings.HasSuffix)
+
+	case filter.All != nil:
+		for i := range filter.All {
+			matched, err := matchEventFilter(&filter.All[i], attrs)
+			if err != nil || !matched {
+				return false, err
+			}
+		}
+		return true, nil
+
+	case filter.Any != nil:
+		for i := range filter.Any {
+			matched, err := matchEventFilter(&filter.Any[i], attrs)
+			if err != nil {
+				return false, err
+			}
+			if matched {
+				return true, nil
+			}
+		}
+		return false, nil
+
+	case filter.Not != nil:
+		matched, err := matchEventFilter(filter.Not, attrs)
+		if err != nil {
+			return false, err
+		}
+		return !matched, nil
+
+	case filter.SQL != "":
+		prog, err := compileSQL(filter.SQL)
+		if err != nil {
+			return false, err
+		}
+		// Errors at evaluation, such as a reference to the missing
+		// attribute, mean that the event does not match.
+		matched, err := prog.EvalBool(attrs)
+		return err == nil && matched, nil
+	}
+
+	return false, errors.New("no filter dialect specified")
+}
+
+// matchAttribute returns true if the value of the attribute in the filter
+// matches the attribute of the event.
+func matchAttribute(filter map[string]string, attrs map[string]interface{}, match func(string, string) bool) (bool, error) {
+	if len(filter) != 1 {
+		return false, errors.New("exactly one attribute must be specified")
+	}
+
+	for name, value := range filter {
+		attr, ok := attrs[name].(string)
+		if !ok {
+			return false, nil
+		}
+		return match(attr, value), nil
+	}
+
+	return false, nil
+}
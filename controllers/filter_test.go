@@ -0,0 +1,119 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	v1alpha1 "github.com/summerwind/eventreactor/api/v1alpha1"
+)
+
+func TestMatchEventFilter(t *testing.T) {
+	newEvent := func(specVersion string) *v1alpha1.Event {
+		ev := &v1alpha1.Event{}
+		ev.Spec = v1alpha1.EventSpec{
+			SpecVersion: specVersion,
+			ID:          "1",
+			Source:      "https://github.com/summerwind/eventreactor",
+			Type:        "com.github.push",
+			Subject:     "main",
+			Extensions:  map[string]string{"sequence": "5"},
+		}
+		return ev
+	}
+
+	tests := []struct {
+		name   string
+		ev     *v1alpha1.Event
+		filter v1alpha1.EventFilter
+		want   bool
+		err    bool
+	}{
+		{
+			name:   "default spec version",
+			ev:     newEvent(""),
+			filter: v1alpha1.EventFilter{Exact: map[string]string{"specversion": "1.0"}},
+			want:   true,
+		},
+		{
+			name:   "spec version of event",
+			ev:     newEvent(v1alpha1.SpecVersion03),
+			filter: v1alpha1.EventFilter{SQL: "specversion = '0.3'"},
+			want:   true,
+		},
+		{
+			name:   "prefix",
+			ev:     newEvent(""),
+			filter: v1alpha1.EventFilter{Prefix: map[string]string{"type": "com.github."}},
+			want:   true,
+		},
+		{
+			name: "all and not",
+			ev:   newEvent(""),
+			filter: v1alpha1.EventFilter{All: []v1alpha1.EventFilter{
+				{Suffix: map[string]string{"source": "/eventreactor"}},
+				{Not: &v1alpha1.EventFilter{Exact: map[string]string{"subject": "develop"}}},
+			}},
+			want: true,
+		},
+		{
+			name: "any",
+			ev:   newEvent(""),
+			filter: v1alpha1.EventFilter{Any: []v1alpha1.EventFilter{
+				{Exact: map[string]string{"subject": "develop"}},
+				{SQL: "sequence > 3"},
+			}},
+			want: true,
+		},
+		{
+			name:   "missing attribute",
+			ev:     newEvent(""),
+			filter: v1alpha1.EventFilter{Exact: map[string]string{"dataschema": ""}},
+			want:   false,
+		},
+		{
+			name:   "sql with missing attribute",
+			ev:     newEvent(""),
+			filter: v1alpha1.EventFilter{SQL: "dataschema = ''"},
+			want:   false,
+		},
+		{
+			name:   "invalid sql",
+			ev:     newEvent(""),
+			filter: v1alpha1.EventFilter{SQL: "subject ="},
+			err:    true,
+		},
+		{
+			name:   "no dialect",
+			ev:     newEvent(""),
+			filter: v1alpha1.EventFilter{},
+			err:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := matchEventFilter(&tt.filter, eventAttributes(tt.ev))
+			if (err != nil) != tt.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("matchEventFilter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
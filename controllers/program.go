@@ -0,0 +1,79 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sync"
+
+	v1alpha1 "github.com/summerwind/eventreactor/api/v1alpha1"
+	"github.com/summerwind/eventreactor/pkg/expr"
+)
+
+// maxCachedPrograms is the maximum number of cached programs. The cache is
+// cleared if it is exceeded, so that the programs of old generations of
+// subscriptions are dropped.
+const maxCachedPrograms = 1024
+
+// programCache caches the compiled filter expressions by their text, so that
+// the filters of subscription are compiled once instead of every event.
+type programCache struct {
+	mu       sync.Mutex
+	programs map[string]*expr.Program
+}
+
+// programs is the cache of filter expressions shared by the controllers.
+var programs = &programCache{programs: map[string]*expr.Program{}}
+
+// get returns the cached program of the key, or compiles and caches it.
+// Programs that fail to compile are not cached.
+func (c *programCache) get(key string, compile func() (*expr.Program, error)) (*expr.Program, error) {
+	c.mu.Lock()
+	prog, ok := c.programs[key]
+	c.mu.Unlock()
+	if ok {
+		return prog, nil
+	}
+
+	prog, err := compile()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.programs) >= maxCachedPrograms {
+		c.programs = map[string]*expr.Program{}
+	}
+	c.programs[key] = prog
+
+	return prog, nil
+}
+
+// compileFilter returns the compiled filter expression of trigger.
+func compileFilter(filter string) (*expr.Program, error) {
+	return programs.get("cel:"+filter, func() (*expr.Program, error) {
+		return expr.Compile(filter, v1alpha1.FilterVariables)
+	})
+}
+
+// compileSQL returns the compiled CloudEvents SQL expression.
+func compileSQL(sql string) (*expr.Program, error) {
+	return programs.get("sql:"+sql, func() (*expr.Program, error) {
+		return expr.CompileSQL(sql)
+	})
+}
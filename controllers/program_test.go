@@ -0,0 +1,66 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/summerwind/eventreactor/pkg/expr"
+)
+
+func TestProgramCache(t *testing.T) {
+	c := &programCache{programs: map[string]*expr.Program{}}
+
+	compiled := 0
+	compile := func(text string) func() (*expr.Program, error) {
+		return func() (*expr.Program, error) {
+			compiled++
+			return expr.Compile(text, []string{"type"})
+		}
+	}
+
+	p1, err := c.get("a", compile(`type == "a"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2, err := c.get("a", compile(`type == "a"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p1 != p2 || compiled != 1 {
+		t.Errorf("program was compiled %d times", compiled)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.get("invalid", compile(`type ==`)); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+	if compiled != 3 {
+		t.Errorf("invalid program was cached")
+	}
+
+	for i := 0; i < maxCachedPrograms; i++ {
+		if _, err := c.get(fmt.Sprint(i), compile(`type == "a"`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(c.programs) > maxCachedPrograms {
+		t.Errorf("number of cached programs = %d, want <= %d", len(c.programs), maxCachedPrograms)
+	}
+}
@@ -0,0 +1,263 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	v1alpha1 "github.com/summerwind/eventreactor/api/v1alpha1"
+)
+
+const (
+	// The length of random suffix of generated names.
+	randomNameLength = 5
+	// The maximum length of generated names.
+	maxGeneratedNameLength = 63
+	// The length of hash suffix of truncated names.
+	nameHashLength = 10
+)
+
+// render renders the resource template at the index of subscription. If
+// the previous result of the subscription has the name of the resource, the
+// name is reused so that retries apply the same resource.
+func render(sub *v1alpha1.Subscription, index int, ev *v1alpha1.Event, data interface{}, prev *v1alpha1.SubscriptionResult) (*unstructured.Unstructured, v1alpha1.ResourceResult, error) {
+	res := sub.Spec.ResourceTemplates[index].DeepCopy()
+
+	result := v1alpha1.ResourceResult{
+		Template:   index,
+		APIVersion: res.GetAPIVersion(),
+		Kind:       res.GetKind(),
+	}
+
+	err := expandVars(res, ev, data, sub.Spec.Parameters)
+	if err != nil {
+		return nil, result, fmt.Errorf("failed to expand variables: %s", err)
+	}
+
+	res.SetNamespace(sub.Namespace)
+
+	name := ""
+	if prevResult := findResourceResult(prev, index); prevResult != nil && prevResult.Kind == res.GetKind() {
+		name = prevResult.Name
+	}
+
+	if name == "" {
+		name, err = resourceName(sub, res, ev, data)
+		if err != nil {
+			return nil, result, fmt.Errorf("failed to determine resource name: %s", err)
+		}
+	}
+
+	res.SetName(name)
+	res.SetGenerateName("")
+
+	labels := res.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[v1alpha1.EventLabel] = labelValue(ev.Name)
+	if subscriptionKind(sub) == v1alpha1.ClusterSubscriptionKind {
+		labels[v1alpha1.ClusterSubscriptionLabel] = labelValue(sub.Name)
+	} else {
+		labels[v1alpha1.SubscriptionLabel] = labelValue(sub.Name)
+	}
+	res.SetLabels(labels)
+
+	switch sub.Spec.Owner {
+	case v1alpha1.OwnerEvent:
+		// Owner references across namespaces are not allowed.
+		if res.GetNamespace() != ev.Namespace {
+			return nil, result, fmt.Errorf("event in namespace %s cannot be the owner of resource in namespace %s", ev.Namespace, res.GetNamespace())
+		}
+		ref := newOwnerReference(ev, v1alpha1.GroupVersion.WithKind("Event"))
+		res.SetOwnerReferences(mergeOwnerReferences(res.GetOwnerReferences(), []metav1.OwnerReference{ref}))
+	case v1alpha1.OwnerSubscription:
+		ref := newOwnerReference(sub, v1alpha1.GroupVersion.WithKind(subscriptionKind(sub)))
+		res.SetOwnerReferences(mergeOwnerReferences(res.GetOwnerReferences(), []metav1.OwnerReference{ref}))
+	}
+
+	result.Namespace = res.GetNamespace()
+	result.Name = res.GetName()
+
+	return res, result, nil
+}
+
+// resourceName returns the name of resource with the name strategy of
+// subscription. The name of resource template or subscription is used as
+// the base of the name.
+func resourceName(sub *v1alpha1.Subscription, res *unstructured.Unstructured, ev *v1alpha1.Event, data interface{}) (string, error) {
+	base := res.GetName()
+	if base == "" {
+		base = sub.Name
+	}
+
+	switch sub.Spec.NameStrategy {
+	case v1alpha1.NameStrategyGenerateName:
+		prefix := res.GetGenerateName()
+		if prefix == "" {
+			prefix = fmt.Sprintf("%s-", base)
+		}
+		return generateName(prefix), nil
+	case v1alpha1.NameStrategyEventName:
+		return truncateName(fmt.Sprintf("%s-%s", base, ev.Name), maxGeneratedNameLength), nil
+	case v1alpha1.NameStrategyTemplate:
+		name, err := expandName(sub.Spec.NameTemplate, ev, data, sub.Spec.Parameters)
+		if err != nil {
+			return "", err
+		}
+		if name == "" {
+			return "", errors.New("name template expanded to empty string")
+		}
+		return name, nil
+	}
+
+	if res.GetName() == "" && res.GetGenerateName() != "" {
+		return generateName(res.GetGenerateName()), nil
+	}
+
+	return base, nil
+}
+
+// generateName returns a name that has the prefix and random suffix.
+func generateName(prefix string) string {
+	maxPrefixLength := maxGeneratedNameLength - randomNameLength
+	if len(prefix) > maxPrefixLength {
+		prefix = prefix[:maxPrefixLength]
+	}
+
+	return fmt.Sprintf("%s%s", prefix, utilrand.String(randomNameLength))
+}
+
+// truncateName returns the name truncated to the maximum length. A hash of
+// the whole name is appended to the truncated name so that different names
+// are not truncated to the same name.
+func truncateName(name string, max int) string {
+	if len(name) <= max {
+		return name
+	}
+
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(name)))[:nameHashLength]
+	prefix := strings.TrimRight(name[:max-nameHashLength-1], "-.")
+
+	return fmt.Sprintf("%s-%s", prefix, hash)
+}
+
+// labelValue returns the name truncated to the maximum length of label
+// values.
+func labelValue(name string) string {
+	return truncateName(name, validation.LabelValueMaxLength)
+}
+
+// newOwnerReference returns an owner reference to the object. The reference
+// is not a controller reference because a resource may be updated by
+// multiple events.
+func newOwnerReference(owner metav1.Object, gvk schema.GroupVersionKind) metav1.OwnerReference {
+	return metav1.OwnerReference{
+		APIVersion: gvk.GroupVersion().String(),
+		Kind:       gvk.Kind,
+		Name:       owner.GetName(),
+		UID:        owner.GetUID(),
+	}
+}
+
+// mergeOwnerReferences returns a new list of owner references that
+// contains references of both lists. References are identified by UID.
+// References to events in dst are replaced with the ones in src, so that a
+// resource updated by every event is not owned by all of them.
+func mergeOwnerReferences(dst, src []metav1.OwnerReference) []metav1.OwnerReference {
+	replaceEvents := false
+	for _, ref := range src {
+		if isEventReference(ref) {
+			replaceEvents = true
+			break
+		}
+	}
+
+	refs := []metav1.OwnerReference{}
+	for _, ref := range dst {
+		if replaceEvents && isEventReference(ref) {
+			continue
+		}
+		refs = append(refs, ref)
+	}
+
+	for _, ref := range src {
+		found := false
+		for i := range refs {
+			if refs[i].UID == ref.UID {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			refs = append(refs, ref)
+		}
+	}
+
+	if len(refs) == 0 {
+		return nil
+	}
+
+	return refs
+}
+
+// isEventReference returns true if the owner reference refers to an event.
+func isEventReference(ref metav1.OwnerReference) bool {
+	return ref.Kind == "Event" && ref.APIVersion == v1alpha1.GroupVersion.String()
+}
+
+// findSubscriptionResult returns the result of subscription that has the
+// kind and the name. Results without kind are the results of subscriptions.
+func findSubscriptionResult(results []v1alpha1.SubscriptionResult, kind, name string) *v1alpha1.SubscriptionResult {
+	for i := range results {
+		resultKind := results[i].Kind
+		if resultKind == "" {
+			resultKind = "Subscription"
+		}
+
+		if resultKind == kind && results[i].Name == name {
+			return &results[i]
+		}
+	}
+
+	return nil
+}
+
+// findResourceResult returns the result of resource template at the index.
+func findResourceResult(result *v1alpha1.SubscriptionResult, index int) *v1alpha1.ResourceResult {
+	if result == nil {
+		return nil
+	}
+
+	for i := range result.Resources {
+		if result.Resources[i].Template == index {
+			return &result.Resources[i]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,275 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	v1alpha1 "github.com/summerwind/eventreactor/api/v1alpha1"
+)
+
+func TestExpandVars(t *testing.T) {
+	ev := &v1alpha1.Event{
+		ObjectMeta: metav1.ObjectMeta{Name: "ev"},
+		Spec:       v1alpha1.EventSpec{Type: "test"},
+	}
+
+	res := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"data": map[string]interface{}{
+			"value":             "(( .Data.value ))",
+			"quoted":            `(( index .Data "value" ))`,
+			"(( .Params.key ))": "(( .Event.Spec.Type ))",
+			"static":            "static",
+		},
+		"list":  []interface{}{"(( .Params.key ))", int64(1), true},
+		"count": int64(3),
+	}}
+	data := map[string]interface{}{"value": `x","injected":"yes`}
+
+	if err := expandVars(res, ev, data, map[string]string{"key": "k"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"data": map[string]interface{}{
+			"value":  `x","injected":"yes`,
+			"quoted": `x","injected":"yes`,
+			"k":      "test",
+			"static": "static",
+		},
+		"list":  []interface{}{"k", int64(1), true},
+		"count": int64(3),
+	}
+	if !reflect.DeepEqual(res.Object, want) {
+		t.Errorf("got %#v, want %#v", res.Object, want)
+	}
+}
+
+func TestResourceName(t *testing.T) {
+	ev := &v1alpha1.Event{
+		ObjectMeta: metav1.ObjectMeta{Name: "01e2xyz"},
+		Spec:       v1alpha1.EventSpec{Type: "test"},
+	}
+	longName := strings.Repeat("a", 60)
+
+	tests := []struct {
+		name       string
+		strategy   v1alpha1.NameStrategy
+		template   string
+		resName    string
+		resGenName string
+		want       string
+		wantPrefix string
+		wantErr    bool
+	}{
+		{
+			name:    "fixed",
+			resName: "fixed",
+			want:    "fixed",
+		},
+		{
+			name: "fixed with subscription name",
+			want: "sub",
+		},
+		{
+			name:       "generateName of template",
+			resGenName: "gen-",
+			wantPrefix: "gen-",
+		},
+		{
+			name:       "generate",
+			strategy:   v1alpha1.NameStrategyGenerateName,
+			resName:    "res",
+			wantPrefix: "res-",
+		},
+		{
+			name:       "generate with generateName",
+			strategy:   v1alpha1.NameStrategyGenerateName,
+			resGenName: "gen-",
+			wantPrefix: "gen-",
+		},
+		{
+			name:       "generate with long name",
+			strategy:   v1alpha1.NameStrategyGenerateName,
+			resName:    longName + longName,
+			wantPrefix: longName[:maxGeneratedNameLength-randomNameLength],
+		},
+		{
+			name:     "event name",
+			strategy: v1alpha1.NameStrategyEventName,
+			resName:  "res",
+			want:     "res-01e2xyz",
+		},
+		{
+			name:     "event name with long name",
+			strategy: v1alpha1.NameStrategyEventName,
+			resName:  longName,
+			want:     truncateName(longName+"-01e2xyz", maxGeneratedNameLength),
+		},
+		{
+			name:     "template",
+			strategy: v1alpha1.NameStrategyTemplate,
+			template: "(( .Event.Spec.Type ))-(( .Params.suffix ))",
+			want:     "test-x",
+		},
+		{
+			name:     "empty template",
+			strategy: v1alpha1.NameStrategyTemplate,
+			template: " ",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid template",
+			strategy: v1alpha1.NameStrategyTemplate,
+			template: "(( .Event.Spec.Type.Foo ))",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := &v1alpha1.Subscription{
+				ObjectMeta: metav1.ObjectMeta{Name: "sub"},
+				Spec: v1alpha1.SubscriptionSpec{
+					NameStrategy: tt.strategy,
+					NameTemplate: tt.template,
+					Parameters:   map[string]string{"suffix": "x"},
+				},
+			}
+			res := &unstructured.Unstructured{}
+			res.SetName(tt.resName)
+			res.SetGenerateName(tt.resGenName)
+
+			got, err := resourceName(sub, res, ev, nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if len(got) > maxGeneratedNameLength {
+				t.Errorf("name %s exceeds %d characters", got, maxGeneratedNameLength)
+			}
+			if tt.wantPrefix != "" {
+				if !strings.HasPrefix(got, tt.wantPrefix) || len(got) != len(tt.wantPrefix)+randomNameLength {
+					t.Errorf("got %s, want prefix %s with random suffix", got, tt.wantPrefix)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncateName(t *testing.T) {
+	short := "short-name"
+	if got := truncateName(short, 63); got != short {
+		t.Errorf("got %s, want %s", got, short)
+	}
+
+	a := truncateName(strings.Repeat("a", 70)+"-1", 63)
+	b := truncateName(strings.Repeat("a", 70)+"-2", 63)
+	if len(a) != 63 || len(b) != 63 {
+		t.Errorf("unexpected length: %d, %d", len(a), len(b))
+	}
+	if a == b {
+		t.Errorf("different names are truncated to the same name: %s", a)
+	}
+
+	// Trailing separators of the truncated prefix are removed.
+	c := truncateName(strings.Repeat("a", 51)+"-"+strings.Repeat("b", 20), 63)
+	if strings.Contains(c, "--") {
+		t.Errorf("got %s, want no trailing separator before hash", c)
+	}
+}
+
+func TestMergeOwnerReferences(t *testing.T) {
+	apiVersion := v1alpha1.GroupVersion.String()
+	ev1 := metav1.OwnerReference{APIVersion: apiVersion, Kind: "Event", Name: "ev1", UID: "1"}
+	ev2 := metav1.OwnerReference{APIVersion: apiVersion, Kind: "Event", Name: "ev2", UID: "2"}
+	sub := metav1.OwnerReference{APIVersion: apiVersion, Kind: "Subscription", Name: "sub", UID: "3"}
+	other := metav1.OwnerReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "app", UID: "4"}
+
+	tests := []struct {
+		name string
+		dst  []metav1.OwnerReference
+		src  []metav1.OwnerReference
+		want []metav1.OwnerReference
+	}{
+		{
+			name: "empty",
+			want: nil,
+		},
+		{
+			name: "add",
+			dst:  []metav1.OwnerReference{other},
+			src:  []metav1.OwnerReference{sub},
+			want: []metav1.OwnerReference{other, sub},
+		},
+		{
+			name: "same uid",
+			dst:  []metav1.OwnerReference{sub, other},
+			src:  []metav1.OwnerReference{sub},
+			want: []metav1.OwnerReference{sub, other},
+		},
+		{
+			name: "replace event",
+			dst:  []metav1.OwnerReference{ev1, other},
+			src:  []metav1.OwnerReference{ev2},
+			want: []metav1.OwnerReference{other, ev2},
+		},
+		{
+			name: "keep event",
+			dst:  []metav1.OwnerReference{ev1},
+			src:  []metav1.OwnerReference{sub},
+			want: []metav1.OwnerReference{ev1, sub},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeOwnerReferences(tt.dst, tt.src)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLabelValue(t *testing.T) {
+	if got := labelValue("sub"); got != "sub" {
+		t.Errorf("got %s, want sub", got)
+	}
+	if got := labelValue(strings.Repeat("a", 100)); len(got) != 63 {
+		t.Errorf("got %d characters, want 63", len(got))
+	}
+}
@@ -0,0 +1,451 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	v1alpha1 "github.com/summerwind/eventreactor/api/v1alpha1"
+	"github.com/summerwind/eventreactor/pkg/blob"
+)
+
+var eventSpecTypeKey = ".spec.type"
+
+const (
+	pruneReasonTTL       = "TTL"
+	pruneReasonMaxEvents = "MaxEvents"
+)
+
+// pruneInterval is the minimum interval of pruning excess events of each
+// type.
+const pruneInterval = time.Minute
+
+var prunedEvents = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "eventreactor_events_pruned_total",
+		Help: "Total number of events pruned by the retention controller",
+	},
+	[]string{"namespace", "reason"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(prunedEvents)
+}
+
+// EventRetentionReconciler deletes dispatched events according to the
+// retention policy of subscriptions and namespaces.
+type EventRetentionReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+
+	// DataStores is the data stores of event payloads keyed by their
+	// types.
+	DataStores map[string]blob.Store
+
+	pruneMu   sync.Mutex
+	lastPrune map[string]time.Time
+}
+
+// +kubebuilder:rbac:groups=eventreactor.summerwind.dev,resources=events,verbs=get;list;watch;delete
+// +kubebuilder:rbac:groups=eventreactor.summerwind.dev,resources=subscriptions,verbs=get;list;watch
+// +kubebuilder:rbac:groups=eventreactor.summerwind.dev,resources=clustersubscriptions,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+func (r *EventRetentionReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("event", req.NamespacedName)
+
+	var instance v1alpha1.Event
+	err := r.Get(ctx, req.NamespacedName, &instance)
+	if err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if instance.Status.DispatchTime == nil || !instance.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	policy, err := r.retentionPolicy(ctx, &instance)
+	if err != nil {
+		log.Error(err, "Failed to get retention policy")
+		return ctrl.Result{}, err
+	}
+
+	ttl := policy.TTLSecondsAfterDispatch
+	if instance.Status.Phase == v1alpha1.EventPhaseFailed && policy.FailedTTLSecondsAfterDispatch != nil {
+		ttl = policy.FailedTTLSecondsAfterDispatch
+	}
+
+	result := ctrl.Result{}
+
+	if policy.MaxEventsPerType != nil && instance.Status.Phase == v1alpha1.EventPhaseSucceeded {
+		// Pruning lists all the events of the type, so it is done at most
+		// once per interval for each type. The event is requeued so that
+		// it is pruned by the next pruning if it has been skipped.
+		key := fmt.Sprintf("%s/%s", instance.Namespace, instance.Spec.Type)
+		if next := r.nextPrune(key); next > 0 {
+			result.RequeueAfter = next
+		} else {
+			err = r.pruneExcessEvents(ctx, &instance, int(*policy.MaxEventsPerType))
+			if err != nil {
+				log.Error(err, "Failed to prune events")
+				return ctrl.Result{}, err
+			}
+		}
+	}
+
+	if ttl == nil {
+		return result, nil
+	}
+
+	expireTime := instance.Status.DispatchTime.Add(time.Duration(*ttl) * time.Second)
+	if remaining := time.Until(expireTime); remaining > 0 {
+		if result.RequeueAfter == 0 || remaining < result.RequeueAfter {
+			result.RequeueAfter = remaining
+		}
+		return result, nil
+	}
+
+	err = r.delete(ctx, &instance, pruneReasonTTL)
+	if err != nil {
+		log.Error(err, "Failed to delete event")
+		return ctrl.Result{}, err
+	}
+	log.Info("Event deleted", "reason", pruneReasonTTL)
+
+	return ctrl.Result{}, nil
+}
+
+// retentionPolicy returns the retention policy of the event. The policy of
+// each matched subscription falls back to the policy of namespace, and the
+// policies are combined so that the event is retained as long as any of
+// them requires.
+func (r *EventRetentionReconciler) retentionPolicy(ctx context.Context, ev *v1alpha1.Event) (*v1alpha1.RetentionPolicy, error) {
+	var ns corev1.Namespace
+	err := r.Get(ctx, types.NamespacedName{Name: ev.Namespace}, &ns)
+	if err != nil {
+		return nil, err
+	}
+
+	nsPolicy := namespaceRetentionPolicy(&ns)
+	if len(ev.Status.Subscriptions) == 0 {
+		return mergeRetentionPolicy(nsPolicy, nil), nil
+	}
+
+	policies := []*v1alpha1.RetentionPolicy{}
+	for _, result := range ev.Status.Subscriptions {
+		retention, err := r.subscriptionRetention(ctx, ev, &result)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		policies = append(policies, mergeRetentionPolicy(nsPolicy, retention))
+	}
+
+	if len(policies) == 0 {
+		return mergeRetentionPolicy(nsPolicy, nil), nil
+	}
+
+	return combineRetentionPolicies(policies), nil
+}
+
+// mergeRetentionPolicy returns the retention policy of subscription that
+// falls back to the policy of namespace. The failed TTL is resolved to the
+// TTL if it is not specified.
+func mergeRetentionPolicy(nsPolicy, retention *v1alpha1.RetentionPolicy) *v1alpha1.RetentionPolicy {
+	policy := nsPolicy.DeepCopy()
+	if retention != nil {
+		if retention.TTLSecondsAfterDispatch != nil {
+			policy.TTLSecondsAfterDispatch = retention.TTLSecondsAfterDispatch
+		}
+		if retention.FailedTTLSecondsAfterDispatch != nil {
+			policy.FailedTTLSecondsAfterDispatch = retention.FailedTTLSecondsAfterDispatch
+		}
+		if retention.MaxEventsPerType != nil {
+			policy.MaxEventsPerType = retention.MaxEventsPerType
+		}
+	}
+
+	// The failed TTL defaults to the TTL of the same policy, so it must be
+	// resolved before the policies are combined.
+	if policy.FailedTTLSecondsAfterDispatch == nil {
+		policy.FailedTTLSecondsAfterDispatch = policy.TTLSecondsAfterDispatch
+	}
+
+	return policy
+}
+
+// combineRetentionPolicies returns the policy that retains events as long
+// as any of the policies requires.
+func combineRetentionPolicies(policies []*v1alpha1.RetentionPolicy) *v1alpha1.RetentionPolicy {
+	policy := policies[0].DeepCopy()
+	for _, p := range policies[1:] {
+		policy.TTLSecondsAfterDispatch = maxInt32(policy.TTLSecondsAfterDispatch, p.TTLSecondsAfterDispatch)
+		policy.FailedTTLSecondsAfterDispatch = maxInt32(policy.FailedTTLSecondsAfterDispatch, p.FailedTTLSecondsAfterDispatch)
+		policy.MaxEventsPerType = maxInt32(policy.MaxEventsPerType, p.MaxEventsPerType)
+	}
+
+	return policy
+}
+
+// subscriptionRetention returns the retention policy of the subscription
+// or the cluster subscription of the result.
+func (r *EventRetentionReconciler) subscriptionRetention(ctx context.Context, ev *v1alpha1.Event, result *v1alpha1.SubscriptionResult) (*v1alpha1.RetentionPolicy, error) {
+	if result.Kind == v1alpha1.ClusterSubscriptionKind {
+		var csub v1alpha1.ClusterSubscription
+		err := r.Get(ctx, types.NamespacedName{Name: result.Name}, &csub)
+		if err != nil {
+			return nil, err
+		}
+		return csub.Spec.Retention, nil
+	}
+
+	var sub v1alpha1.Subscription
+	err := r.Get(ctx, types.NamespacedName{Namespace: ev.Namespace, Name: result.Name}, &sub)
+	if err != nil {
+		return nil, err
+	}
+	return sub.Spec.Retention, nil
+}
+
+// nextPrune returns the duration until the excess events of the key can be
+// pruned. It returns zero and records the time of pruning if they can be
+// pruned now.
+func (r *EventRetentionReconciler) nextPrune(key string) time.Duration {
+	r.pruneMu.Lock()
+	defer r.pruneMu.Unlock()
+
+	now := time.Now()
+	if last, ok := r.lastPrune[key]; ok {
+		if remaining := last.Add(pruneInterval).Sub(now); remaining > 0 {
+			return remaining
+		}
+	}
+
+	// Remove the keys that can be pruned again so that the map does not
+	// grow with the types of events.
+	for k, last := range r.lastPrune {
+		if !now.Before(last.Add(pruneInterval)) {
+			delete(r.lastPrune, k)
+		}
+	}
+
+	if r.lastPrune == nil {
+		r.lastPrune = map[string]time.Time{}
+	}
+	r.lastPrune[key] = now
+
+	return 0
+}
+
+// pruneExcessEvents deletes the oldest succeeded events that have the same
+// type as the event if the number of them exceeds the limit.
+func (r *EventRetentionReconciler) pruneExcessEvents(ctx context.Context, ev *v1alpha1.Event, max int) error {
+	opts := []client.ListOption{
+		client.InNamespace(ev.Namespace),
+		client.MatchingFields{eventSpecTypeKey: ev.Spec.Type},
+	}
+
+	var eventList v1alpha1.EventList
+	err := r.List(ctx, &eventList, opts...)
+	if err != nil {
+		return err
+	}
+
+	events := []v1alpha1.Event{}
+	for _, item := range eventList.Items {
+		if item.Status.Phase != v1alpha1.EventPhaseSucceeded || item.Status.DispatchTime == nil || !item.DeletionTimestamp.IsZero() {
+			continue
+		}
+		events = append(events, item)
+	}
+
+	if len(events) <= max {
+		return nil
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[j].Status.DispatchTime.Before(events[i].Status.DispatchTime)
+	})
+
+	for i := range events[max:] {
+		err := r.delete(ctx, &events[max+i], pruneReasonMaxEvents)
+		if err != nil {
+			return err
+		}
+		r.Log.Info("Event deleted", "event", types.NamespacedName{Namespace: events[max+i].Namespace, Name: events[max+i].Name}, "reason", pruneReasonMaxEvents)
+	}
+
+	return nil
+}
+
+func (r *EventRetentionReconciler) delete(ctx context.Context, ev *v1alpha1.Event, reason string) error {
+	err := r.Delete(ctx, ev, client.PropagationPolicy(metav1.DeletePropagationBackground))
+	if err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	prunedEvents.WithLabelValues(ev.Namespace, reason).Inc()
+
+	// The payload in the store that does not support owner references is
+	// deleted with the event. Dead letters refer to the payload of the
+	// original event, so it is not deleted with them. Invalid references
+	// are ignored so that the event cannot delete arbitrary data.
+	if ref := ev.Spec.DataRef; ref != nil && ref.Namespace == "" && len(v1alpha1.ValidateDataReference(ev)) == 0 {
+		store, ok := r.DataStores[ref.Store]
+		if _, owned := store.(blob.OwnedStore); ok && !owned {
+			if err := store.Delete(ctx, ev.Namespace, ref.Name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *EventRetentionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	err := mgr.GetFieldIndexer().IndexField(&v1alpha1.Event{}, eventSpecTypeKey, func(obj runtime.Object) []string {
+		ev := obj.(*v1alpha1.Event)
+		return []string{ev.Spec.Type}
+	})
+	if err != nil {
+		return err
+	}
+
+	// Reconcile all events in the namespace when the retention policy of
+	// namespace has been changed.
+	mapper := handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+		var eventList v1alpha1.EventList
+		err := r.List(context.Background(), &eventList, client.InNamespace(obj.Meta.GetName()))
+		if err != nil {
+			r.Log.Error(err, "Failed to get event list", "namespace", obj.Meta.GetName())
+			return nil
+		}
+
+		reqs := make([]reconcile.Request, len(eventList.Items))
+		for i, ev := range eventList.Items {
+			reqs[i] = reconcile.Request{NamespacedName: types.NamespacedName{Namespace: ev.Namespace, Name: ev.Name}}
+		}
+
+		return reqs
+	})
+
+	c, err := ctrl.NewControllerManagedBy(mgr).
+		Named("eventretention").
+		For(&v1alpha1.Event{}).
+		Build(r)
+	if err != nil {
+		return err
+	}
+
+	return c.Watch(&source.Kind{Type: &corev1.Namespace{}}, &handler.EnqueueRequestsFromMapFunc{ToRequests: mapper}, retentionPolicyChanged)
+}
+
+// retentionAnnotations is the list of annotations of namespace that specify
+// the retention policy.
+var retentionAnnotations = []string{
+	v1alpha1.TTLSecondsAfterDispatchAnnotation,
+	v1alpha1.FailedTTLSecondsAfterDispatchAnnotation,
+	v1alpha1.MaxEventsPerTypeAnnotation,
+}
+
+// retentionPolicyChanged passes only the updates of namespaces that change
+// the retention annotations, so that unrelated changes of namespaces do not
+// reconcile all events in the namespace. Events are reconciled at startup,
+// so the other events of namespaces are ignored.
+var retentionPolicyChanged = predicate.Funcs{
+	CreateFunc:  func(event.CreateEvent) bool { return false },
+	DeleteFunc:  func(event.DeleteEvent) bool { return false },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		if e.MetaOld == nil || e.MetaNew == nil {
+			return false
+		}
+
+		oldAnnotations := e.MetaOld.GetAnnotations()
+		newAnnotations := e.MetaNew.GetAnnotations()
+		for _, key := range retentionAnnotations {
+			if oldAnnotations[key] != newAnnotations[key] {
+				return true
+			}
+		}
+
+		return false
+	},
+}
+
+// namespaceRetentionPolicy returns the retention policy specified by the
+// annotations of namespace.
+func namespaceRetentionPolicy(ns *corev1.Namespace) *v1alpha1.RetentionPolicy {
+	annotations := ns.GetAnnotations()
+
+	return &v1alpha1.RetentionPolicy{
+		TTLSecondsAfterDispatch:       parseInt32(annotations[v1alpha1.TTLSecondsAfterDispatchAnnotation]),
+		FailedTTLSecondsAfterDispatch: parseInt32(annotations[v1alpha1.FailedTTLSecondsAfterDispatchAnnotation]),
+		MaxEventsPerType:              parseInt32(annotations[v1alpha1.MaxEventsPerTypeAnnotation]),
+	}
+}
+
+func parseInt32(s string) *int32 {
+	if s == "" {
+		return nil
+	}
+
+	v, err := strconv.ParseInt(s, 10, 32)
+	if err != nil || v < 0 {
+		return nil
+	}
+
+	i := int32(v)
+	return &i
+}
+
+// maxInt32 returns the larger value. nil is treated as unlimited.
+func maxInt32(a, b *int32) *int32 {
+	if a == nil || b == nil {
+		return nil
+	}
+	if *a > *b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,260 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	v1alpha1 "github.com/summerwind/eventreactor/api/v1alpha1"
+)
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+// newTestScheme returns a scheme that has the types of core and
+// eventreactor API.
+func newTestScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return scheme
+}
+
+func TestRetentionPolicy(t *testing.T) {
+	newSub := func(name string, retention *v1alpha1.RetentionPolicy) runtime.Object {
+		return &v1alpha1.Subscription{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Spec:       v1alpha1.SubscriptionSpec{Retention: retention},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		objs        []runtime.Object
+		results     []v1alpha1.SubscriptionResult
+		want        *v1alpha1.RetentionPolicy
+	}{
+		{
+			name: "no policy",
+			want: &v1alpha1.RetentionPolicy{},
+		},
+		{
+			name: "namespace",
+			annotations: map[string]string{
+				v1alpha1.TTLSecondsAfterDispatchAnnotation: "60",
+				v1alpha1.MaxEventsPerTypeAnnotation:        "10",
+			},
+			want: &v1alpha1.RetentionPolicy{
+				TTLSecondsAfterDispatch:       int32Ptr(60),
+				FailedTTLSecondsAfterDispatch: int32Ptr(60),
+				MaxEventsPerType:              int32Ptr(10),
+			},
+		},
+		{
+			name: "subscription overrides namespace",
+			annotations: map[string]string{
+				v1alpha1.TTLSecondsAfterDispatchAnnotation:       "60",
+				v1alpha1.FailedTTLSecondsAfterDispatchAnnotation: "600",
+			},
+			objs: []runtime.Object{
+				newSub("a", &v1alpha1.RetentionPolicy{TTLSecondsAfterDispatch: int32Ptr(30)}),
+			},
+			results: []v1alpha1.SubscriptionResult{{Name: "a"}},
+			want: &v1alpha1.RetentionPolicy{
+				TTLSecondsAfterDispatch:       int32Ptr(30),
+				FailedTTLSecondsAfterDispatch: int32Ptr(600),
+			},
+		},
+		{
+			name: "failed ttl defaults to ttl of each subscription",
+			objs: []runtime.Object{
+				newSub("a", &v1alpha1.RetentionPolicy{TTLSecondsAfterDispatch: int32Ptr(60)}),
+				newSub("b", &v1alpha1.RetentionPolicy{TTLSecondsAfterDispatch: int32Ptr(120), FailedTTLSecondsAfterDispatch: int32Ptr(3600)}),
+			},
+			results: []v1alpha1.SubscriptionResult{{Name: "a"}, {Name: "b"}},
+			want: &v1alpha1.RetentionPolicy{
+				TTLSecondsAfterDispatch:       int32Ptr(120),
+				FailedTTLSecondsAfterDispatch: int32Ptr(3600),
+			},
+		},
+		{
+			name: "unlimited subscription",
+			objs: []runtime.Object{
+				newSub("a", &v1alpha1.RetentionPolicy{TTLSecondsAfterDispatch: int32Ptr(60), MaxEventsPerType: int32Ptr(5)}),
+				newSub("b", nil),
+			},
+			results: []v1alpha1.SubscriptionResult{{Name: "a"}, {Name: "b"}},
+			want:    &v1alpha1.RetentionPolicy{},
+		},
+		{
+			name: "max events",
+			objs: []runtime.Object{
+				newSub("a", &v1alpha1.RetentionPolicy{MaxEventsPerType: int32Ptr(5)}),
+				newSub("b", &v1alpha1.RetentionPolicy{MaxEventsPerType: int32Ptr(10)}),
+			},
+			results: []v1alpha1.SubscriptionResult{{Name: "a"}, {Name: "b"}},
+			want:    &v1alpha1.RetentionPolicy{MaxEventsPerType: int32Ptr(10)},
+		},
+		{
+			name: "cluster subscription",
+			objs: []runtime.Object{
+				newSub("a", &v1alpha1.RetentionPolicy{TTLSecondsAfterDispatch: int32Ptr(60)}),
+				&v1alpha1.ClusterSubscription{
+					ObjectMeta: metav1.ObjectMeta{Name: "a"},
+					Spec: v1alpha1.ClusterSubscriptionSpec{
+						SubscriptionSpec: v1alpha1.SubscriptionSpec{
+							Retention: &v1alpha1.RetentionPolicy{TTLSecondsAfterDispatch: int32Ptr(300)},
+						},
+					},
+				},
+			},
+			results: []v1alpha1.SubscriptionResult{{Kind: v1alpha1.ClusterSubscriptionKind, Name: "a"}},
+			want: &v1alpha1.RetentionPolicy{
+				TTLSecondsAfterDispatch:       int32Ptr(300),
+				FailedTTLSecondsAfterDispatch: int32Ptr(300),
+			},
+		},
+		{
+			name: "deleted subscription",
+			annotations: map[string]string{
+				v1alpha1.TTLSecondsAfterDispatchAnnotation: "60",
+			},
+			results: []v1alpha1.SubscriptionResult{{Name: "a"}},
+			want: &v1alpha1.RetentionPolicy{
+				TTLSecondsAfterDispatch:       int32Ptr(60),
+				FailedTTLSecondsAfterDispatch: int32Ptr(60),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: "default", Annotations: tt.annotations},
+			}
+			r := &EventRetentionReconciler{
+				Client: fake.NewFakeClientWithScheme(newTestScheme(t), append(tt.objs, ns)...),
+				Log:    log.NullLogger{},
+			}
+			ev := &v1alpha1.Event{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "ev"},
+				Status:     v1alpha1.EventStatus{Subscriptions: tt.results},
+			}
+
+			got, err := r.retentionPolicy(context.Background(), ev)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextPrune(t *testing.T) {
+	r := &EventRetentionReconciler{}
+
+	if next := r.nextPrune("default/a"); next != 0 {
+		t.Errorf("got %s, want 0 for the first pruning", next)
+	}
+	if next := r.nextPrune("default/a"); next <= 0 || next > pruneInterval {
+		t.Errorf("got %s, want the remaining interval", next)
+	}
+	if next := r.nextPrune("default/b"); next != 0 {
+		t.Errorf("got %s, want 0 for another type", next)
+	}
+}
+
+func TestRetentionPolicyChanged(t *testing.T) {
+	newNamespace := func(annotations, labels map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "default", Annotations: annotations, Labels: labels},
+		}
+	}
+
+	tests := []struct {
+		name string
+		old  *corev1.Namespace
+		new  *corev1.Namespace
+		want bool
+	}{
+		{
+			name: "annotation added",
+			old:  newNamespace(nil, nil),
+			new:  newNamespace(map[string]string{v1alpha1.MaxEventsPerTypeAnnotation: "10"}, nil),
+			want: true,
+		},
+		{
+			name: "annotation changed",
+			old:  newNamespace(map[string]string{v1alpha1.TTLSecondsAfterDispatchAnnotation: "60"}, nil),
+			new:  newNamespace(map[string]string{v1alpha1.TTLSecondsAfterDispatchAnnotation: "120"}, nil),
+			want: true,
+		},
+		{
+			name: "annotation removed",
+			old:  newNamespace(map[string]string{v1alpha1.FailedTTLSecondsAfterDispatchAnnotation: "60"}, nil),
+			new:  newNamespace(nil, nil),
+			want: true,
+		},
+		{
+			name: "other annotation changed",
+			old:  newNamespace(map[string]string{"a": "1", v1alpha1.TTLSecondsAfterDispatchAnnotation: "60"}, nil),
+			new:  newNamespace(map[string]string{"a": "2", v1alpha1.TTLSecondsAfterDispatchAnnotation: "60"}, nil),
+			want: false,
+		},
+		{
+			name: "label changed",
+			old:  newNamespace(nil, map[string]string{"a": "1"}),
+			new:  newNamespace(nil, map[string]string{"a": "2"}),
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := event.UpdateEvent{MetaOld: tt.old, ObjectOld: tt.old, MetaNew: tt.new, ObjectNew: tt.new}
+			if got := retentionPolicyChanged.Update(e); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	ns := newNamespace(map[string]string{v1alpha1.MaxEventsPerTypeAnnotation: "10"}, nil)
+	if retentionPolicyChanged.Create(event.CreateEvent{Meta: ns, Object: ns}) {
+		t.Error("create events must be ignored")
+	}
+	if retentionPolicyChanged.Delete(event.DeleteEvent{Meta: ns, Object: ns}) {
+		t.Error("delete events must be ignored")
+	}
+}
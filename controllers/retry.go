@@ -0,0 +1,224 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	v1alpha1 "github.com/summerwind/eventreactor/api/v1alpha1"
+)
+
+const (
+	defaultMaxAttempts = 5
+	defaultBackoffBase = 5 * time.Second
+	defaultBackoffCap  = 5 * time.Minute
+)
+
+// retryPolicy returns the retry policy of subscription with defaults.
+func retryPolicy(sub *v1alpha1.Subscription) *v1alpha1.RetryPolicy {
+	policy := &v1alpha1.RetryPolicy{}
+	if sub.Spec.RetryPolicy != nil {
+		policy = sub.Spec.RetryPolicy.DeepCopy()
+	}
+
+	if policy.MaxAttempts == nil {
+		maxAttempts := int32(defaultMaxAttempts)
+		policy.MaxAttempts = &maxAttempts
+	}
+	if policy.BackoffBase == nil {
+		policy.BackoffBase = &metav1.Duration{Duration: defaultBackoffBase}
+	}
+	if policy.BackoffCap == nil {
+		policy.BackoffCap = &metav1.Duration{Duration: defaultBackoffCap}
+	}
+
+	return policy
+}
+
+// backoff returns the delay before the next retry with the number of
+// attempts that have been made.
+func backoff(policy *v1alpha1.RetryPolicy, attempts int32) time.Duration {
+	delay := policy.BackoffBase.Duration
+	for i := int32(1); i < attempts; i++ {
+		delay *= 2
+		if delay >= policy.BackoffCap.Duration {
+			return policy.BackoffCap.Duration
+		}
+	}
+
+	if delay > policy.BackoffCap.Duration {
+		return policy.BackoffCap.Duration
+	}
+
+	return delay
+}
+
+// permanentError is the error that is not resolved by retries, such as an
+// error of rendering resource templates.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+// permanent marks the error as permanent.
+func permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// isPermanent returns true if the error is not resolved by retries.
+func isPermanent(err error) bool {
+	_, ok := err.(*permanentError)
+	return ok
+}
+
+// countFailedResources returns the number of resources that could not be
+// applied.
+func countFailedResources(result *v1alpha1.SubscriptionResult) int {
+	failed := 0
+	for _, res := range result.Resources {
+		if res.Error != "" {
+			failed++
+		}
+	}
+
+	return failed
+}
+
+// deadLetter copies the event to the dead letter namespace and emits an
+// event of dispatch failure as specified by the dead letter policy of
+// subscription.
+func (r *EventReconciler) deadLetter(ctx context.Context, sub *v1alpha1.Subscription, ev *v1alpha1.Event, result *v1alpha1.SubscriptionResult) error {
+	policy := sub.Spec.DeadLetter
+	if policy == nil {
+		return nil
+	}
+
+	source := fmt.Sprintf("%s/%s", ev.Namespace, ev.Name)
+
+	// The namespace is validated by the webhook and the reconciler of
+	// subscription, but it is checked again since the allowed namespaces
+	// may have been changed after the validation.
+	if policy.Namespace != "" && !v1alpha1.IsDeadLetterNamespace(policy.Namespace) {
+		r.Log.Info("Dead letter namespace is not allowed", "subscription", fmt.Sprintf("%s/%s", sub.Namespace, sub.Name), "namespace", policy.Namespace)
+	} else if policy.Namespace != "" {
+		dl := &v1alpha1.Event{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: policy.Namespace,
+				Name:      ev.Name,
+				Labels:    ev.Labels,
+				Annotations: mergeMap(ev.Annotations, map[string]string{
+					v1alpha1.DeadLetterAnnotation: source,
+				}),
+			},
+			Spec: *ev.Spec.DeepCopy(),
+		}
+		dl.Status.Phase = v1alpha1.EventPhasePending
+
+		// The payload in the data store stays in the namespace of the
+		// original event.
+		if dl.Spec.DataRef != nil && dl.Spec.DataRef.Namespace == "" {
+			dl.Spec.DataRef.Namespace = ev.Namespace
+		}
+
+		err := r.Create(ctx, dl)
+		if err != nil && !errors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+
+	// Do not emit the event of dispatch failure for the event of dispatch
+	// failure to avoid infinite chain of events.
+	if policy.EmitEvent && ev.Spec.Type != v1alpha1.DispatchFailedEventType {
+		errs := []string{}
+		for _, res := range result.Resources {
+			if res.Error != "" {
+				errs = append(errs, res.Error)
+			}
+		}
+
+		data, err := json.Marshal(map[string]interface{}{
+			"event": map[string]string{
+				"namespace": ev.Namespace,
+				"name":      ev.Name,
+				"id":        ev.Spec.ID,
+				"source":    ev.Spec.Source,
+				"type":      ev.Spec.Type,
+			},
+			"subscription": sub.Name,
+			"attempts":     result.Attempts,
+			"errors":       errs,
+		})
+		if err != nil {
+			return err
+		}
+
+		subSource := fmt.Sprintf("/apis/%s/namespaces/%s/subscriptions/%s", v1alpha1.GroupVersion, sub.Namespace, sub.Name)
+		if subscriptionKind(sub) == v1alpha1.ClusterSubscriptionKind {
+			subSource = fmt.Sprintf("/apis/%s/clustersubscriptions/%s", v1alpha1.GroupVersion, sub.Name)
+		}
+
+		now := metav1.Now()
+		failure := &v1alpha1.Event{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: ev.Namespace,
+				Name:      failureEventName(ev, sub),
+			},
+			Spec: v1alpha1.EventSpec{
+				ID:              v1alpha1.NewEventName(),
+				Source:          subSource,
+				Type:            v1alpha1.DispatchFailedEventType,
+				DataContentType: "application/json",
+				Subject:         source,
+				Time:            &now,
+				Data:            string(data),
+			},
+		}
+		failure.Status.Phase = v1alpha1.EventPhasePending
+
+		err = r.Create(ctx, failure)
+		if err != nil && !errors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// failureEventName returns the name of the event of dispatch failure. The
+// name contains a hash of the kind, namespace and name of subscription so
+// that a subscription and a cluster subscription with the same name do not
+// share the name.
+func failureEventName(ev *v1alpha1.Event, sub *v1alpha1.Subscription) string {
+	key := fmt.Sprintf("%s/%s/%s", subscriptionKind(sub), sub.Namespace, sub.Name)
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(key)))[:nameHashLength]
+
+	return truncateName(fmt.Sprintf("%s-%s-%s", ev.Name, sub.Name, hash), validation.DNS1123SubdomainMaxLength)
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	v1alpha1 "github.com/summerwind/eventreactor/api/v1alpha1"
+)
+
+func TestIsPermanent(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"permanent", permanent(errors.New("invalid template")), true},
+		{"transient", errors.New("connection refused"), false},
+		{"wrapped", fmt.Errorf("failed: %w", permanent(errors.New("invalid template"))), false},
+		{"nil", permanent(nil), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isPermanent(tt.err); got != tt.want {
+				t.Errorf("isPermanent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	defer func(namespaces []string) {
+		v1alpha1.DeadLetterNamespaces = namespaces
+	}(v1alpha1.DeadLetterNamespaces)
+	v1alpha1.DeadLetterNamespaces = []string{"dead-letters"}
+
+	tests := []struct {
+		name      string
+		namespace string
+		want      bool
+	}{
+		{"allowed namespace", "dead-letters", true},
+		{"forbidden namespace", "kube-system", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &EventReconciler{
+				Client: fake.NewFakeClientWithScheme(newTestScheme(t)),
+				Log:    log.NullLogger{},
+			}
+
+			sub := &v1alpha1.Subscription{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "sub"},
+				Spec: v1alpha1.SubscriptionSpec{
+					DeadLetter: &v1alpha1.DeadLetterPolicy{Namespace: tt.namespace},
+				},
+			}
+			ev := &v1alpha1.Event{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "ev"},
+				Spec: v1alpha1.EventSpec{
+					ID:     "1",
+					Source: "/test",
+					Type:   "test",
+				},
+			}
+
+			err := r.deadLetter(context.Background(), sub, ev, &v1alpha1.SubscriptionResult{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var dl v1alpha1.Event
+			err = r.Get(context.Background(), types.NamespacedName{Namespace: tt.namespace, Name: ev.Name}, &dl)
+			if err != nil && !apierrors.IsNotFound(err) {
+				t.Fatal(err)
+			}
+			if got := err == nil; got != tt.want {
+				t.Errorf("dead letter created = %v, want %v", got, tt.want)
+			}
+			if err == nil && dl.Annotations[v1alpha1.DeadLetterAnnotation] != "default/ev" {
+				t.Errorf("unexpected annotation: %v", dl.Annotations)
+			}
+		})
+	}
+}
+
+func TestFailureEventName(t *testing.T) {
+	ev := &v1alpha1.Event{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "ev"}}
+	sub := &v1alpha1.Subscription{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "sub"}}
+	csub := sub.DeepCopy()
+	csub.Kind = v1alpha1.ClusterSubscriptionKind
+	long := sub.DeepCopy()
+	long.Name = strings.Repeat("a", 253)
+
+	name := failureEventName(ev, sub)
+	if !strings.HasPrefix(name, "ev-sub-") {
+		t.Errorf("got %s, want prefix ev-sub-", name)
+	}
+	if name != failureEventName(ev, sub.DeepCopy()) {
+		t.Errorf("name is not stable: %s", name)
+	}
+	if cname := failureEventName(ev, csub); cname == name {
+		t.Errorf("got the same name %s for subscription and cluster subscription", name)
+	}
+	if lname := failureEventName(ev, long); len(lname) > validation.DNS1123SubdomainMaxLength {
+		t.Errorf("got %d characters, want at most %d", len(lname), validation.DNS1123SubdomainMaxLength)
+	}
+}
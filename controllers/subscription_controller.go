@@ -18,36 +18,218 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
-	eventreactorv1alpha1 "github.com/summerwind/eventreactor/api/v1alpha1"
+	v1alpha1 "github.com/summerwind/eventreactor/api/v1alpha1"
 )
 
+const (
+	reasonValid                   = "Valid"
+	reasonInvalidType             = "InvalidType"
+	reasonInvalidMatchSource      = "InvalidMatchSource"
+	reasonInvalidMatchSubject     = "InvalidMatchSubject"
+	reasonInvalidMatchExtensions  = "InvalidMatchExtensions"
+	reasonInvalidFilter           = "InvalidFilter"
+	reasonInvalidMatchFilters     = "InvalidMatchFilters"
+	reasonInvalidNameTemplate     = "InvalidNameTemplate"
+	reasonInvalidResourceTemplate = "InvalidResourceTemplate"
+	reasonUnknownResourceKind     = "UnknownResourceKind"
+	reasonInvalidDeadLetter       = "InvalidDeadLetter"
+	reasonInvalidSpec             = "InvalidSpec"
+)
+
+// unknownKindRequeueInterval is the interval to validate the subscription
+// again if the kind of resource template is unknown.
+const unknownKindRequeueInterval = time.Minute
+
 // SubscriptionReconciler reconciles a Subscription object
 type SubscriptionReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
+
+	mapper meta.RESTMapper
 }
 
 // +kubebuilder:rbac:groups=eventreactor.summerwind.dev,resources=subscriptions,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=eventreactor.summerwind.dev,resources=subscriptions/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=eventreactor.summerwind.dev,resources=resourcetemplates,verbs=get;list;watch
 
 func (r *SubscriptionReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
-	_ = context.Background()
-	_ = r.Log.WithValues("subscription", req.NamespacedName)
+	ctx := context.Background()
+	log := r.Log.WithValues("subscription", req.NamespacedName)
+
+	var instance v1alpha1.Subscription
+	err := r.Get(ctx, req.NamespacedName, &instance)
+	if err != nil {
+		log.Error(err, "Failed to get subscription")
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// The spec is resolved with the referenced resource template, so
+	// validate a copy of it.
+	spec := instance.Spec.DeepCopy()
+
+	generation, reason, message, err := validateTemplateRef(ctx, r, spec, instance.Namespace)
+	if err == nil && reason == reasonValid {
+		reason, message = validationResult(v1alpha1.ValidateSubscriptionSpec(spec, field.NewPath("spec")))
+	}
+	if err == nil && reason == reasonValid {
+		reason, message, err = validateResourceKinds(spec.ResourceTemplates, r.mapper)
+	}
+	if err != nil {
+		log.Error(err, "Failed to validate subscription")
+		return ctrl.Result{}, err
+	}
+
+	result := ctrl.Result{}
+	if reason == reasonValid {
+		message = "Subscription is valid"
+	} else if reason == reasonUnknownResourceKind {
+		// The CRD of resource may be installed after the subscription.
+		result.RequeueAfter = unknownKindRequeueInterval
+	}
+
+	sub := instance.DeepCopy()
+	sub.Status.ObservedGeneration = instance.Generation
+	sub.Status.TemplateGeneration = generation
+
+	if reason == reasonValid {
+		sub.Status.SetCondition(v1alpha1.SubscriptionReady, corev1.ConditionTrue, reason, message)
+		sub.Status.SetCondition(v1alpha1.SubscriptionInvalid, corev1.ConditionFalse, reason, message)
+	} else {
+		log.Info("Invalid subscription", "reason", reason, "message", message)
+		sub.Status.SetCondition(v1alpha1.SubscriptionReady, corev1.ConditionFalse, reason, message)
+		sub.Status.SetCondition(v1alpha1.SubscriptionInvalid, corev1.ConditionTrue, reason, message)
+	}
+
+	if equality.Semantic.DeepEqual(instance.Status, sub.Status) {
+		return result, nil
+	}
+
+	err = r.Status().Update(ctx, sub)
+	if err != nil {
+		log.Error(err, "Failed to update subscription")
+		return ctrl.Result{}, err
+	}
+
+	return result, nil
+}
+
+// fieldReasons is the list of reasons of invalid subscriptions with the
+// path prefix of invalid fields. The first matched prefix is used.
+var fieldReasons = []struct {
+	path   string
+	reason string
+}{
+	{"spec.trigger.type", reasonInvalidType},
+	{"spec.trigger.matchSource", reasonInvalidMatchSource},
+	{"spec.trigger.matchSubject", reasonInvalidMatchSubject},
+	{"spec.trigger.matchExtensions", reasonInvalidMatchExtensions},
+	{"spec.trigger.matchFilters", reasonInvalidMatchFilters},
+	{"spec.trigger.filters", reasonInvalidFilter},
+	{"spec.nameTemplate", reasonInvalidNameTemplate},
+	{"spec.resourceTemplates", reasonInvalidResourceTemplate},
+	{"spec.templateRef", reasonInvalidTemplateRef},
+	{"spec.deadLetter", reasonInvalidDeadLetter},
+	{"spec.namespaceSelector", reasonInvalidNamespaceSelector},
+	{"spec.targetNamespaceSelector", reasonInvalidTargetNamespaceSelector},
+	{"spec.targetNamespace", reasonInvalidTargetNamespace},
+}
+
+// validationResult returns the reason and message of the validation errors
+// of subscription spec. The reason is decided by the first invalid field.
+func validationResult(errs field.ErrorList) (string, string) {
+	if len(errs) == 0 {
+		return reasonValid, ""
+	}
+
+	reason := reasonInvalidSpec
+	for _, fr := range fieldReasons {
+		if strings.HasPrefix(errs[0].Field, fr.path) {
+			reason = fr.reason
+			break
+		}
+	}
+
+	return reason, errs.ToAggregate().Error()
+}
 
-	// your logic here
+// validateResourceKinds checks that the kinds of resource templates are
+// served by the API server. It returns the reason and message of the
+// validation result. An error is returned only if the validation could not
+// be completed.
+func validateResourceKinds(templates []unstructured.Unstructured, mapper meta.RESTMapper) (string, string, error) {
+	for i, tmpl := range templates {
+		gvk := tmpl.GroupVersionKind()
+		_, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			if meta.IsNoMatchError(err) {
+				return reasonUnknownResourceKind, fmt.Sprintf("Unknown resource kind at index %d: %s", i, gvk), nil
+			}
+			return "", "", err
+		}
+	}
 
-	return ctrl.Result{}, nil
+	return reasonValid, "", nil
 }
 
 func (r *SubscriptionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.mapper = mgr.GetRESTMapper()
+
+	err := mgr.GetFieldIndexer().IndexField(&v1alpha1.Subscription{}, templateRefKey, func(obj runtime.Object) []string {
+		sub := obj.(*v1alpha1.Subscription)
+		if sub.Spec.TemplateRef == nil {
+			return nil
+		}
+		name, err := templateRefName(sub.Spec.TemplateRef, sub.Namespace)
+		if err != nil {
+			return nil
+		}
+		return []string{name.String()}
+	})
+	if err != nil {
+		return err
+	}
+
+	// Reconcile all subscriptions that refer to the resource template when
+	// it has been changed.
+	mapper := handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+		name := types.NamespacedName{Namespace: obj.Meta.GetNamespace(), Name: obj.Meta.GetName()}
+
+		var subList v1alpha1.SubscriptionList
+		err := r.List(context.Background(), &subList, client.InNamespace(name.Namespace), client.MatchingFields{templateRefKey: name.String()})
+		if err != nil {
+			r.Log.Error(err, "Failed to get subscription list", "resourcetemplate", name)
+			return nil
+		}
+
+		reqs := make([]reconcile.Request, len(subList.Items))
+		for i, sub := range subList.Items {
+			reqs[i] = reconcile.Request{NamespacedName: types.NamespacedName{Namespace: sub.Namespace, Name: sub.Name}}
+		}
+
+		return reqs
+	})
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&eventreactorv1alpha1.Subscription{}).
+		For(&v1alpha1.Subscription{}).
+		Watches(&source.Kind{Type: &v1alpha1.ResourceTemplate{}}, &handler.EnqueueRequestsFromMapFunc{ToRequests: mapper}).
 		Complete(r)
 }
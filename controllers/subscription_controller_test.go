@@ -0,0 +1,76 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestValidationResult(t *testing.T) {
+	tests := []struct {
+		name   string
+		errs   field.ErrorList
+		reason string
+	}{
+		{
+			name:   "valid",
+			reason: reasonValid,
+		},
+		{
+			name:   "types",
+			errs:   field.ErrorList{field.Invalid(field.NewPath("spec", "trigger", "types").Index(0), "a*", "invalid")},
+			reason: reasonInvalidType,
+		},
+		{
+			name:   "match filters",
+			errs:   field.ErrorList{field.Required(field.NewPath("spec", "trigger", "matchFilters").Index(0), "required")},
+			reason: reasonInvalidMatchFilters,
+		},
+		{
+			name: "first error",
+			errs: field.ErrorList{
+				field.Invalid(field.NewPath("spec", "nameTemplate"), "((", "invalid"),
+				field.Invalid(field.NewPath("spec", "trigger", "filters").Index(0), "(", "invalid"),
+			},
+			reason: reasonInvalidNameTemplate,
+		},
+		{
+			name:   "target namespace selector",
+			errs:   field.ErrorList{field.Required(field.NewPath("spec", "targetNamespaceSelector"), "required")},
+			reason: reasonInvalidTargetNamespaceSelector,
+		},
+		{
+			name:   "unknown field",
+			errs:   field.ErrorList{field.Forbidden(field.NewPath("spec", "owner"), "forbidden")},
+			reason: reasonInvalidSpec,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, message := validationResult(tt.errs)
+			if reason != tt.reason {
+				t.Errorf("got reason %s, want %s", reason, tt.reason)
+			}
+			if len(tt.errs) > 0 && message == "" {
+				t.Error("expected message")
+			}
+		})
+	}
+}
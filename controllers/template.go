@@ -0,0 +1,168 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1alpha1 "github.com/summerwind/eventreactor/api/v1alpha1"
+)
+
+// templateRefKey is the index key of the namespaced name of ResourceTemplate
+// referenced by subscriptions.
+var templateRefKey = ".spec.templateRef"
+
+const (
+	reasonInvalidTemplateRef       = "InvalidTemplateRef"
+	reasonResourceTemplateNotFound = "ResourceTemplateNotFound"
+	reasonInvalidParameters        = "InvalidParameters"
+)
+
+// templateRefName returns the namespaced name of ResourceTemplate referenced
+// by the subscription spec. The namespace is the namespace of subscription,
+// and it is empty for cluster subscriptions. Subscriptions can refer only to
+// the resource templates in their namespace.
+func templateRefName(ref *v1alpha1.TemplateReference, namespace string) (types.NamespacedName, error) {
+	name := types.NamespacedName{
+		Namespace: ref.Namespace,
+		Name:      ref.Name,
+	}
+
+	if namespace == "" {
+		if name.Namespace == "" {
+			return name, errors.New("namespace of resource template must be specified")
+		}
+		return name, nil
+	}
+
+	if name.Namespace == "" {
+		name.Namespace = namespace
+	}
+	if name.Namespace != namespace {
+		return name, fmt.Errorf("resource template in namespace %s cannot be referred from namespace %s", name.Namespace, namespace)
+	}
+
+	return name, nil
+}
+
+// templateParameters returns the values of parameters of the resource
+// template. The default values are used for the parameters that are not
+// specified. An error is returned if a required parameter is missing or an
+// unknown parameter is specified.
+func templateParameters(tmpl *v1alpha1.ResourceTemplate, values map[string]string) (map[string]string, error) {
+	params := map[string]string{}
+	for _, param := range tmpl.Spec.Parameters {
+		value, ok := values[param.Name]
+		if !ok {
+			if param.Required {
+				return nil, fmt.Errorf("parameter %s is required", param.Name)
+			}
+			value = param.Default
+		}
+		params[param.Name] = value
+	}
+
+	// Sort the names so that the error is stable.
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, ok := params[name]; !ok {
+			return nil, fmt.Errorf("unknown parameter %s", name)
+		}
+	}
+
+	return params, nil
+}
+
+// resolveResourceTemplate replaces the resource templates and parameters of
+// the subscription spec with the ones of the referenced ResourceTemplate.
+// The namespace is same as templateRefName. The template reference is
+// removed from the resolved spec. It does nothing if the spec does not have
+// a template reference.
+func resolveResourceTemplate(ctx context.Context, c client.Reader, spec *v1alpha1.SubscriptionSpec, namespace string) error {
+	if spec.TemplateRef == nil {
+		return nil
+	}
+
+	name, err := templateRefName(spec.TemplateRef, namespace)
+	if err != nil {
+		return permanent(err)
+	}
+
+	var tmpl v1alpha1.ResourceTemplate
+	err = c.Get(ctx, name, &tmpl)
+	if err != nil {
+		return err
+	}
+
+	params, err := templateParameters(&tmpl, spec.Parameters)
+	if err != nil {
+		return permanent(err)
+	}
+
+	spec.ResourceTemplates = tmpl.Spec.ResourceTemplates
+	spec.Parameters = params
+	spec.TemplateRef = nil
+
+	return nil
+}
+
+// validateTemplateRef resolves the ResourceTemplate referenced by the
+// subscription spec in place, and returns its generation with the reason
+// and message of the validation result. An error is returned only if the
+// validation could not be completed.
+func validateTemplateRef(ctx context.Context, c client.Reader, spec *v1alpha1.SubscriptionSpec, namespace string) (int64, string, string, error) {
+	if spec.TemplateRef == nil {
+		return 0, reasonValid, "", nil
+	}
+
+	name, err := templateRefName(spec.TemplateRef, namespace)
+	if err != nil {
+		return 0, reasonInvalidTemplateRef, fmt.Sprintf("Invalid resource template reference: %s", err), nil
+	}
+
+	var tmpl v1alpha1.ResourceTemplate
+	err = c.Get(ctx, name, &tmpl)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return 0, reasonResourceTemplateNotFound, fmt.Sprintf("Resource template %s not found", name), nil
+		}
+		return 0, "", "", err
+	}
+
+	params, err := templateParameters(&tmpl, spec.Parameters)
+	if err != nil {
+		return tmpl.Generation, reasonInvalidParameters, fmt.Sprintf("Invalid parameters for resource template %s: %s", name, err), nil
+	}
+
+	spec.ResourceTemplates = tmpl.Spec.ResourceTemplates
+	spec.Parameters = params
+	spec.TemplateRef = nil
+
+	return tmpl.Generation, reasonValid, "", nil
+}
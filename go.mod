@@ -11,12 +11,16 @@ require (
 	github.com/oklog/ulid/v2 v2.0.2
 	github.com/onsi/ginkgo v1.8.0
 	github.com/onsi/gomega v1.5.0
+	github.com/prometheus/client_golang v0.9.2
 	github.com/prometheus/common v0.0.0-20181126121408-4724e9255275
 	github.com/spf13/cobra v0.0.5
 	github.com/tektoncd/pipeline v0.9.2
+	golang.org/x/time v0.0.0-20181108054448-85acf8d2951c
 	gopkg.in/alecthomas/kingpin.v2 v2.2.6 // indirect
+	k8s.io/api v0.0.0-20190918155943-95b840bb6a1f
 	k8s.io/apimachinery v0.0.0-20190913080033-27d36303b655
 	k8s.io/client-go v0.0.0-20190918160344-1fbdaa4c8d90
 	knative.dev/pkg v0.0.0-20200117205703-d99cc30f66f9 // indirect
 	sigs.k8s.io/controller-runtime v0.4.0
+	sigs.k8s.io/yaml v1.1.0
 )
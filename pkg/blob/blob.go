@@ -0,0 +1,53 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package blob provides the stores of event payloads that are too large to
+// be stored in Event resources.
+package blob
+
+import (
+	"context"
+	"errors"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ErrNotFound is returned if the data does not exist in the store.
+var ErrNotFound = errors.New("data not found")
+
+// Store stores the payload of events. Each data is identified by the
+// namespace and the name of event.
+type Store interface {
+	// Type returns the type of store that is recorded in the data
+	// reference of events.
+	Type() string
+	// Put stores the data.
+	Put(ctx context.Context, namespace, name string, data []byte) error
+	// Get returns the data. It returns ErrNotFound if the data does not
+	// exist.
+	Get(ctx context.Context, namespace, name string) ([]byte, error)
+	// Delete deletes the data. It does not return an error if the data
+	// does not exist.
+	Delete(ctx context.Context, namespace, name string) error
+}
+
+// OwnedStore is a store whose data can be owned by the event so that the
+// data is deleted with the event by garbage collection.
+type OwnedStore interface {
+	Store
+	// SetOwner sets the owner reference to the data.
+	SetOwner(ctx context.Context, namespace, name string, owner metav1.OwnerReference) error
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package blob
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	v1alpha1 "github.com/summerwind/eventreactor/api/v1alpha1"
+)
+
+// FileSystemStore stores the data in files under the directory. The
+// directory must be shared by the receiver and the controller, such as a
+// volume that supports ReadWriteMany access mode.
+type FileSystemStore struct {
+	dir string
+}
+
+// NewFileSystemStore returns a new FileSystemStore.
+func NewFileSystemStore(dir string) *FileSystemStore {
+	return &FileSystemStore{dir: dir}
+}
+
+// Type implements Store.
+func (s *FileSystemStore) Type() string {
+	return v1alpha1.DataStoreFileSystem
+}
+
+// Put implements Store. The data is written to a temporary file and then
+// renamed so that readers never see partially written data.
+func (s *FileSystemStore) Put(ctx context.Context, namespace, name string, data []byte) error {
+	path, err := s.path(namespace, name)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(dir, "."+name)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// Get implements Store.
+func (s *FileSystemStore) Get(ctx context.Context, namespace, name string) ([]byte, error) {
+	path, err := s.path(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+
+	return data, err
+}
+
+// Delete implements Store.
+func (s *FileSystemStore) Delete(ctx context.Context, namespace, name string) error {
+	path, err := s.path(namespace, name)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// path returns the path of the file that contains the data. An error is
+// returned if the cleaned path is outside of the directory of namespace in
+// the store, so that the data outside of the store cannot be accessed.
+func (s *FileSystemStore) path(namespace, name string) (string, error) {
+	dir := filepath.Clean(s.dir)
+	nsDir := filepath.Join(dir, namespace)
+	path := filepath.Join(nsDir, name)
+
+	if !isWithin(dir, nsDir) || !isWithin(nsDir, path) {
+		return "", fmt.Errorf("invalid data path: %s/%s", namespace, name)
+	}
+
+	return path, nil
+}
+
+// isWithin returns true if the path is under the directory.
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+
+	return rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
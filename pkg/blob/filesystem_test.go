@@ -0,0 +1,108 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package blob
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFileSystemStorePath(t *testing.T) {
+	s := NewFileSystemStore("/data")
+
+	tests := []struct {
+		namespace string
+		name      string
+		want      string
+		err       bool
+	}{
+		{namespace: "default", name: "ev", want: "/data/default/ev"},
+		{namespace: "default", name: "a/../ev", want: "/data/default/ev"},
+		{namespace: "default", name: "../ev", err: true},
+		{namespace: "default", name: "../../etc/passwd", err: true},
+		{namespace: "default", name: "", err: true},
+		{namespace: "default", name: ".", err: true},
+		{namespace: "..", name: "ev", err: true},
+		{namespace: "", name: "ev", err: true},
+		{namespace: "default", name: "/etc/passwd", want: "/data/default/etc/passwd"},
+	}
+
+	for _, tt := range tests {
+		got, err := s.path(tt.namespace, tt.name)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s/%s: expected error, got %s", tt.namespace, tt.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s/%s: unexpected error: %s", tt.namespace, tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s/%s: got %s, want %s", tt.namespace, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFileSystemStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "secret"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	s := NewFileSystemStore(filepath.Join(dir, "store"))
+
+	if err := s.Put(ctx, "default", "ev", []byte("data")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	data, err := s.Get(ctx, "default", "ev")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(data, []byte("data")) {
+		t.Errorf("got %q, want %q", data, "data")
+	}
+
+	if _, err := s.Get(ctx, "default", "../../secret"); err == nil || err == ErrNotFound {
+		t.Errorf("got %v, want invalid path error", err)
+	}
+	if err := s.Delete(ctx, "default", "../../secret"); err == nil {
+		t.Error("expected error for path outside of the store")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "secret")); err != nil {
+		t.Errorf("file outside of the store was deleted: %s", err)
+	}
+
+	if err := s.Delete(ctx, "default", "ev"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := s.Get(ctx, "default", "ev"); err != ErrNotFound {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+}
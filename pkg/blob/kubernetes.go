@@ -0,0 +1,190 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package blob
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1alpha1 "github.com/summerwind/eventreactor/api/v1alpha1"
+)
+
+// The key of ConfigMap and Secret that contains the data.
+const dataKey = "data"
+
+// ConfigMapStore stores the data in ConfigMaps in the namespace of event.
+// Only ConfigMaps that have the label of the event are read.
+type ConfigMapStore struct {
+	client client.Client
+}
+
+// NewConfigMapStore returns a new ConfigMapStore.
+func NewConfigMapStore(c client.Client) *ConfigMapStore {
+	return &ConfigMapStore{client: c}
+}
+
+// Type implements Store.
+func (s *ConfigMapStore) Type() string {
+	return v1alpha1.DataStoreConfigMap
+}
+
+// Put implements Store.
+func (s *ConfigMapStore) Put(ctx context.Context, namespace, name string, data []byte) error {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: newObjectMeta(namespace, name),
+		BinaryData: map[string][]byte{dataKey: data},
+	}
+
+	return s.client.Create(ctx, cm)
+}
+
+// Get implements Store.
+func (s *ConfigMapStore) Get(ctx context.Context, namespace, name string) ([]byte, error) {
+	var cm corev1.ConfigMap
+	if err := s.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &cm); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	if !isEventData(&cm.ObjectMeta, name) {
+		return nil, ErrNotFound
+	}
+
+	if data, ok := cm.BinaryData[dataKey]; ok {
+		return data, nil
+	}
+	if data, ok := cm.Data[dataKey]; ok {
+		return []byte(data), nil
+	}
+
+	return nil, ErrNotFound
+}
+
+// Delete implements Store.
+func (s *ConfigMapStore) Delete(ctx context.Context, namespace, name string) error {
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+	return client.IgnoreNotFound(s.client.Delete(ctx, cm))
+}
+
+// SetOwner implements OwnedStore.
+func (s *ConfigMapStore) SetOwner(ctx context.Context, namespace, name string, owner metav1.OwnerReference) error {
+	return setOwner(ctx, s.client, &corev1.ConfigMap{}, namespace, name, owner)
+}
+
+// SecretStore stores the data in Secrets in the namespace of event. Only
+// Secrets that have the label of the event are read.
+type SecretStore struct {
+	client client.Client
+}
+
+// NewSecretStore returns a new SecretStore.
+func NewSecretStore(c client.Client) *SecretStore {
+	return &SecretStore{client: c}
+}
+
+// Type implements Store.
+func (s *SecretStore) Type() string {
+	return v1alpha1.DataStoreSecret
+}
+
+// Put implements Store.
+func (s *SecretStore) Put(ctx context.Context, namespace, name string, data []byte) error {
+	secret := &corev1.Secret{
+		ObjectMeta: newObjectMeta(namespace, name),
+		Type:       corev1.SecretTypeOpaque,
+		Data:       map[string][]byte{dataKey: data},
+	}
+
+	return s.client.Create(ctx, secret)
+}
+
+// Get implements Store.
+func (s *SecretStore) Get(ctx context.Context, namespace, name string) ([]byte, error) {
+	var secret corev1.Secret
+	if err := s.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	if !isEventData(&secret.ObjectMeta, name) {
+		return nil, ErrNotFound
+	}
+
+	data, ok := secret.Data[dataKey]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return data, nil
+}
+
+// Delete implements Store.
+func (s *SecretStore) Delete(ctx context.Context, namespace, name string) error {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+	return client.IgnoreNotFound(s.client.Delete(ctx, secret))
+}
+
+// SetOwner implements OwnedStore.
+func (s *SecretStore) SetOwner(ctx context.Context, namespace, name string, owner metav1.OwnerReference) error {
+	return setOwner(ctx, s.client, &corev1.Secret{}, namespace, name, owner)
+}
+
+// newObjectMeta returns the metadata of the object that contains the data
+// of the event.
+func newObjectMeta(namespace, name string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: namespace,
+		Name:      name,
+		Labels: map[string]string{
+			v1alpha1.EventLabel: name,
+		},
+	}
+}
+
+// isEventData returns true if the object has the label of the event, so
+// that ConfigMaps and Secrets that are not created as the data of events
+// cannot be read through events.
+func isEventData(meta *metav1.ObjectMeta, name string) bool {
+	return meta.Labels[v1alpha1.EventLabel] == name
+}
+
+func setOwner(ctx context.Context, c client.Client, obj runtime.Object, namespace, name string, owner metav1.OwnerReference) error {
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, obj); err != nil {
+		return err
+	}
+
+	meta, err := apimeta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+
+	patch := client.MergeFrom(obj.DeepCopyObject())
+	meta.SetOwnerReferences(append(meta.GetOwnerReferences(), owner))
+
+	return c.Patch(ctx, obj, patch)
+}
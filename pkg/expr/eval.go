@@ -0,0 +1,549 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package expr
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type node interface {
+	eval(vars map[string]interface{}) (interface{}, error)
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n *literalNode) eval(vars map[string]interface{}) (interface{}, error) {
+	return n.value, nil
+}
+
+type identNode struct {
+	name string
+}
+
+func (n *identNode) eval(vars map[string]interface{}) (interface{}, error) {
+	v, ok := vars[n.name]
+	if !ok {
+		return nil, fmt.Errorf("no such attribute: %s", n.name)
+	}
+	return normalize(v), nil
+}
+
+type selectNode struct {
+	operand node
+	field   string
+}
+
+func (n *selectNode) eval(vars map[string]interface{}) (interface{}, error) {
+	v, err := n.operand.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	return lookup(v, n.field)
+}
+
+type indexNode struct {
+	operand node
+	index   node
+}
+
+func (n *indexNode) eval(vars map[string]interface{}) (interface{}, error) {
+	v, err := n.operand.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := n.index.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	switch i := index.(type) {
+	case string:
+		return lookup(v, i)
+	case *big.Rat:
+		list, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("cannot index %s with a number", typeName(v))
+		}
+		if !i.IsInt() || i.Sign() < 0 || i.Num().Cmp(big.NewInt(int64(len(list)))) >= 0 {
+			return nil, fmt.Errorf("index out of range: %s", formatNumber(i))
+		}
+		return normalize(list[i.Num().Int64()]), nil
+	}
+
+	return nil, fmt.Errorf("invalid index type: %s", typeName(index))
+}
+
+type listNode struct {
+	items []node
+}
+
+func (n *listNode) eval(vars map[string]interface{}) (interface{}, error) {
+	list := make([]interface{}, len(n.items))
+	for i, item := range n.items {
+		v, err := item.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = v
+	}
+	return list, nil
+}
+
+type unaryNode struct {
+	op      string
+	operand node
+}
+
+func (n *unaryNode) eval(vars map[string]interface{}) (interface{}, error) {
+	v, err := n.operand.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "!":
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("operator '!' is not defined for %s", typeName(v))
+		}
+		return !b, nil
+	case "-":
+		r, ok := v.(*big.Rat)
+		if !ok {
+			return nil, fmt.Errorf("operator '-' is not defined for %s", typeName(v))
+		}
+		return new(big.Rat).Neg(r), nil
+	}
+
+	return nil, fmt.Errorf("unknown operator: %s", n.op)
+}
+
+type binaryNode struct {
+	op    string
+	left  node
+	right node
+}
+
+func (n *binaryNode) eval(vars map[string]interface{}) (interface{}, error) {
+	left, err := n.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	// Logical operators are short-circuited.
+	if n.op == "&&" || n.op == "||" {
+		l, ok := left.(bool)
+		if !ok {
+			return nil, fmt.Errorf("operator '%s' is not defined for %s", n.op, typeName(left))
+		}
+		if (n.op == "&&" && !l) || (n.op == "||" && l) {
+			return l, nil
+		}
+
+		right, err := n.right.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		r, ok := right.(bool)
+		if !ok {
+			return nil, fmt.Errorf("operator '%s' is not defined for %s", n.op, typeName(right))
+		}
+		return r, nil
+	}
+
+	right, err := n.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "in":
+		switch r := right.(type) {
+		case []interface{}:
+			for _, item := range r {
+				if equal(left, normalize(item)) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case map[string]interface{}:
+			key, ok := left.(string)
+			if !ok {
+				return false, nil
+			}
+			_, ok = r[key]
+			return ok, nil
+		case map[string]string:
+			key, ok := left.(string)
+			if !ok {
+				return false, nil
+			}
+			_, ok = r[key]
+			return ok, nil
+		}
+		return nil, fmt.Errorf("operator 'in' is not defined for %s", typeName(right))
+	}
+
+	switch l := left.(type) {
+	case *big.Rat:
+		r, ok := right.(*big.Rat)
+		if !ok {
+			break
+		}
+		switch n.op {
+		case "<":
+			return l.Cmp(r) < 0, nil
+		case "<=":
+			return l.Cmp(r) <= 0, nil
+		case ">":
+			return l.Cmp(r) > 0, nil
+		case ">=":
+			return l.Cmp(r) >= 0, nil
+		case "+":
+			return new(big.Rat).Add(l, r), nil
+		case "-":
+			return new(big.Rat).Sub(l, r), nil
+		case "*":
+			return new(big.Rat).Mul(l, r), nil
+		case "/":
+			if r.Sign() == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			return new(big.Rat).Quo(l, r), nil
+		case "%":
+			if r.Sign() == 0 {
+				return nil, fmt.Errorf("modulus by zero")
+			}
+			// The result has the same sign as the dividend.
+			q := new(big.Rat).Quo(l, r)
+			q.SetInt(new(big.Int).Quo(q.Num(), q.Denom()))
+			return new(big.Rat).Sub(l, q.Mul(q, r)), nil
+		}
+
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			break
+		}
+		switch n.op {
+		case "<":
+			return l < r, nil
+		case "<=":
+			return l <= r, nil
+		case ">":
+			return l > r, nil
+		case ">=":
+			return l >= r, nil
+		case "+":
+			return l + r, nil
+		}
+	}
+
+	return nil, fmt.Errorf("operator '%s' is not defined for %s and %s", n.op, typeName(left), typeName(right))
+}
+
+type callNode struct {
+	name   string
+	target node
+	args   []node
+}
+
+func (n *callNode) eval(vars map[string]interface{}) (interface{}, error) {
+	if n.target == nil && n.name == "has" {
+		return n.has(vars)
+	}
+
+	args := []interface{}{}
+	if n.target != nil {
+		v, err := n.target.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+	for _, arg := range n.args {
+		v, err := arg.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+
+	switch n.name {
+	case "size":
+		switch v := args[0].(type) {
+		case string:
+			return big.NewRat(int64(utf8.RuneCountInString(v)), 1), nil
+		case []interface{}:
+			return big.NewRat(int64(len(v)), 1), nil
+		case map[string]interface{}:
+			return big.NewRat(int64(len(v)), 1), nil
+		case map[string]string:
+			return big.NewRat(int64(len(v)), 1), nil
+		}
+		return nil, fmt.Errorf("function 'size' is not defined for %s", typeName(args[0]))
+
+	case "string":
+		switch v := args[0].(type) {
+		case string:
+			return v, nil
+		case *big.Rat:
+			return formatNumber(v), nil
+		case bool:
+			return strconv.FormatBool(v), nil
+		}
+		return nil, fmt.Errorf("function 'string' is not defined for %s", typeName(args[0]))
+
+	case "double":
+		switch v := args[0].(type) {
+		case *big.Rat:
+			return v, nil
+		case string:
+			r, err := parseNumber(strings.TrimSpace(v))
+			if err != nil {
+				return nil, fmt.Errorf("cannot convert %q to double", v)
+			}
+			return r, nil
+		}
+		return nil, fmt.Errorf("function 'double' is not defined for %s", typeName(args[0]))
+	}
+
+	// The remaining functions are methods of string.
+	s, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("method '%s' is not defined for %s", n.name, typeName(args[0]))
+	}
+	arg, ok := args[1].(string)
+	if !ok {
+		return nil, fmt.Errorf("argument of '%s' must be a string", n.name)
+	}
+
+	switch n.name {
+	case "startsWith":
+		return strings.HasPrefix(s, arg), nil
+	case "endsWith":
+		return strings.HasSuffix(s, arg), nil
+	case "contains":
+		return strings.Contains(s, arg), nil
+	case "matches":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString(s), nil
+	}
+
+	return nil, fmt.Errorf("unknown function: %s", n.name)
+}
+
+// has returns whether the field of the argument exists.
+func (n *callNode) has(vars map[string]interface{}) (interface{}, error) {
+	var operand, key node
+	switch arg := n.args[0].(type) {
+	case *selectNode:
+		operand, key = arg.operand, &literalNode{value: arg.field}
+	case *indexNode:
+		operand, key = arg.operand, arg.index
+	}
+
+	v, err := operand.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	k, err := key.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	switch m := v.(type) {
+	case map[string]interface{}:
+		name, ok := k.(string)
+		if !ok {
+			return false, nil
+		}
+		_, ok = m[name]
+		return ok, nil
+	case map[string]string:
+		name, ok := k.(string)
+		if !ok {
+			return false, nil
+		}
+		_, ok = m[name]
+		return ok, nil
+	}
+
+	return false, nil
+}
+
+// lookup returns the value of the field of the map.
+func lookup(v interface{}, field string) (interface{}, error) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		fv, ok := m[field]
+		if !ok {
+			return nil, fmt.Errorf("no such key: %s", field)
+		}
+		return normalize(fv), nil
+	case map[string]string:
+		fv, ok := m[field]
+		if !ok {
+			return nil, fmt.Errorf("no such key: %s", field)
+		}
+		return fv, nil
+	}
+
+	return nil, fmt.Errorf("cannot select field '%s' from %s", field, typeName(v))
+}
+
+// parseNumber parses the number. Integers are parsed exactly, and other
+// numbers are parsed as float64 so that number literals are equal to the
+// same numbers in data.
+func parseNumber(s string) (*big.Rat, error) {
+	if isInteger(s) {
+		r, ok := new(big.Rat).SetString(s)
+		if !ok {
+			return nil, fmt.Errorf("invalid number: %s", s)
+		}
+		return r, nil
+	}
+
+	// Numbers are not parsed with big.Rat directly since it allocates
+	// the memory for the exponent, such as 1e1000000000.
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, fmt.Errorf("invalid number: %s", s)
+	}
+
+	return new(big.Rat).SetFloat64(f), nil
+}
+
+// isInteger returns true if s is a decimal integer with optional sign.
+func isInteger(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if !isDigit(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// formatNumber returns the string representation of the number.
+// Non-integers are formatted as float64.
+func formatNumber(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	f, _ := r.Float64()
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// normalize converts the numbers to big.Rat so that they can be compared
+// with number literals exactly.
+func normalize(v interface{}) interface{} {
+	switch n := v.(type) {
+	case json.Number:
+		if r, err := parseNumber(n.String()); err == nil {
+			return r
+		}
+		return n.String()
+	case int:
+		return big.NewRat(int64(n), 1)
+	case int32:
+		return big.NewRat(int64(n), 1)
+	case int64:
+		return big.NewRat(n, 1)
+	case uint64:
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(n))
+	case float32:
+		return normalizeFloat(float64(n))
+	case float64:
+		return normalizeFloat(n)
+	}
+	return v
+}
+
+// normalizeFloat converts the finite number to big.Rat. Infinity and NaN are
+// kept as is since they cannot be represented by big.Rat.
+func normalizeFloat(f float64) interface{} {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return f
+	}
+	return new(big.Rat).SetFloat64(f)
+}
+
+// equal returns whether the values are equal.
+func equal(a, b interface{}) bool {
+	a, b = normalize(a), normalize(b)
+
+	if ra, ok := a.(*big.Rat); ok {
+		rb, ok := b.(*big.Rat)
+		return ok && ra.Cmp(rb) == 0
+	}
+
+	la, ok := a.([]interface{})
+	if lb, ok2 := b.([]interface{}); ok && ok2 {
+		if len(la) != len(lb) {
+			return false
+		}
+		for i := range la {
+			if !equal(la[i], lb[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// typeName returns the name of the type of value used in error messages.
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case *big.Rat, float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}, map[string]string:
+		return "map"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package expr provides a small expression language for filtering events.
+// The syntax is a subset of Common Expression Language (CEL): literals,
+// field selection, indexing, arithmetic, comparison, logical operators, the
+// 'in' operator, the 'has' and 'size' functions, and the 'startsWith',
+// 'endsWith', 'contains' and 'matches' methods of strings. Integers are
+// compared exactly regardless of their size, and other numbers are compared
+// as float64.
+//
+//	data.ref == "refs/heads/main" && data.action in ["opened", "synchronize"]
+//
+// Expressions written in CloudEvents SQL (CESQL) are also supported with
+// CompileSQL.
+//
+//	type LIKE 'com.github.%' AND subject IN ('main', 'develop')
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Program is a compiled expression.
+type Program struct {
+	root node
+}
+
+// Compile parses the expression. Top-level identifiers in the expression
+// must be one of vars.
+func Compile(text string, vars []string) (*Program, error) {
+	tokens, err := tokenize(text, celDialect)
+	if err != nil {
+		return nil, err
+	}
+
+	known := map[string]bool{}
+	for _, v := range vars {
+		known[v] = true
+	}
+
+	p := &parser{tokens: tokens, vars: known}
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %s at position %d", tok, tok.pos)
+	}
+
+	return &Program{root: root}, nil
+}
+
+// Eval evaluates the expression with the variables. Numbers in the result
+// are float64.
+func (p *Program) Eval(vars map[string]interface{}) (interface{}, error) {
+	v, err := p.root.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	return export(v), nil
+}
+
+// export converts the numbers in the value to float64.
+func export(v interface{}) interface{} {
+	switch n := v.(type) {
+	case *big.Rat:
+		f, _ := n.Float64()
+		return f
+	case []interface{}:
+		list := make([]interface{}, len(n))
+		for i := range n {
+			list[i] = export(n[i])
+		}
+		return list
+	}
+	return v
+}
+
+// EvalBool evaluates the expression and returns its result as a boolean.
+func (p *Program) EvalBool(vars map[string]interface{}) (bool, error) {
+	v, err := p.Eval(vars)
+	if err != nil {
+		return false, err
+	}
+
+	b, ok := v.(bool)
+	if !ok {
+		return false, errors.New("expression must be evaluated to a boolean")
+	}
+
+	return b, nil
+}
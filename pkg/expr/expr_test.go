@@ -0,0 +1,267 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package expr
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+var testVars = []string{"subject", "extensions", "data"}
+
+func testValues() map[string]interface{} {
+	return map[string]interface{}{
+		"subject": "日本語",
+		"extensions": map[string]string{
+			"foo": "bar",
+			"baz": "qux",
+		},
+		"data": map[string]interface{}{
+			"id":    json.Number("12345678901234567890"),
+			"big":   json.Number("9007199254740993"),
+			"ratio": json.Number("0.1"),
+			"small": json.Number("1e-5"),
+			"list":  []interface{}{json.Number("1"), "two", true},
+			"名前":    "x",
+		},
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	type tok struct {
+		kind tokenKind
+		text string
+	}
+
+	tests := []struct {
+		text   string
+		tokens []tok
+		err    bool
+	}{
+		{
+			text: `a.b >= 1.5`,
+			tokens: []tok{
+				{tokenIdent, "a"}, {tokenOperator, "."}, {tokenIdent, "b"},
+				{tokenOperator, ">="}, {tokenNumber, "1.5"},
+			},
+		},
+		{
+			text:   `1e-5 2E+3 3e4`,
+			tokens: []tok{{tokenNumber, "1e-5"}, {tokenNumber, "2E+3"}, {tokenNumber, "3e4"}},
+		},
+		{
+			text:   `a[0].b`,
+			tokens: []tok{{tokenIdent, "a"}, {tokenOperator, "["}, {tokenNumber, "0"}, {tokenOperator, "]"}, {tokenOperator, "."}, {tokenIdent, "b"}},
+		},
+		{
+			text:   `"日本" + 名前`,
+			tokens: []tok{{tokenString, "日本"}, {tokenOperator, "+"}, {tokenIdent, "名前"}},
+		},
+		{
+			text:   `'it\'s' "a\tb" "é"`,
+			tokens: []tok{{tokenString, "it's"}, {tokenString, "a\tb"}, {tokenString, "é"}},
+		},
+		{
+			text:   `!a&&b||c`,
+			tokens: []tok{{tokenOperator, "!"}, {tokenIdent, "a"}, {tokenOperator, "&&"}, {tokenIdent, "b"}, {tokenOperator, "||"}, {tokenIdent, "c"}},
+		},
+		{text: `1e`, err: true},
+		{text: `1e+`, err: true},
+		{text: `12abc`, err: true},
+		{text: `1ex`, err: true},
+		{text: `"abc`, err: true},
+		{text: `a # b`, err: true},
+		{text: `a ＃ b`, err: true},
+		{text: "\xff", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			tokens, err := tokenize(tt.text, celDialect)
+			if (err != nil) != tt.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.err {
+				return
+			}
+
+			got := []tok{}
+			for _, tk := range tokens {
+				if tk.kind != tokenEOF {
+					got = append(got, tok{tk.kind, tk.text})
+				}
+			}
+			if !reflect.DeepEqual(got, tt.tokens) {
+				t.Errorf("tokens = %v, want %v", got, tt.tokens)
+			}
+		})
+	}
+}
+
+func TestCompileError(t *testing.T) {
+	tests := []string{
+		`unknown == "a"`,
+		`size()`,
+		`size(data, data)`,
+		`unknown(data)`,
+		`subject.unknown()`,
+		`subject.startsWith()`,
+		`has(data)`,
+		`subject.matches("(")`,
+		`data.`,
+		`data[0`,
+		`1 +`,
+		`1 2`,
+		`in`,
+		`(subject`,
+		`1e`,
+	}
+
+	for _, text := range tests {
+		t.Run(text, func(t *testing.T) {
+			if _, err := Compile(text, testVars); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		text string
+		want interface{}
+		err  bool
+	}{
+		// Precedence and associativity.
+		{text: `1 + 2 * 3`, want: 7.0},
+		{text: `(1 + 2) * 3`, want: 9.0},
+		{text: `10 - 4 - 3`, want: 3.0},
+		{text: `-2 * 3`, want: -6.0},
+		{text: `7 / 2`, want: 3.5},
+		{text: `7 % 3`, want: 1.0},
+		{text: `-7 % 2`, want: -1.0},
+		{text: `true || false && false`, want: true},
+		{text: `!true || true`, want: true},
+		{text: `1 + 1 == 2 && "a" < "b"`, want: true},
+		{text: `!(1 < 2)`, want: false},
+
+		// Numbers.
+		{text: `1e-5 == 0.00001`, want: true},
+		{text: `1.5e3 == 1500`, want: true},
+		{text: `2E+2 == 200`, want: true},
+		{text: `data.small == 1e-5`, want: true},
+		{text: `data.ratio == 0.1`, want: true},
+		{text: `data.id == 12345678901234567890`, want: true},
+		{text: `data.id == 12345678901234567891`, want: false},
+		{text: `data.big > 9007199254740992`, want: true},
+		{text: `data.list[0] == 1.0`, want: true},
+		{text: `string(data.id)`, want: "12345678901234567890"},
+		{text: `string(1.5)`, want: "1.5"},
+		{text: `double("1e3") == 1000`, want: true},
+
+		// Strings.
+		{text: `subject == "日本語"`, want: true},
+		{text: `subject.startsWith("日本")`, want: true},
+		{text: `size(subject)`, want: 3.0},
+		{text: `subject + "!"`, want: "日本語!"},
+		{text: `data.名前`, want: "x"},
+		{text: `"abc".matches("^a.c$")`, want: true},
+		{text: `"abc".contains("b") && "abc".endsWith("c")`, want: true},
+
+		// Collections.
+		{text: `size(extensions)`, want: 2.0},
+		{text: `extensions.size()`, want: 2.0},
+		{text: `data.list.size()`, want: 3.0},
+		{text: `size(data)`, want: 6.0},
+		{text: `extensions.foo == "bar"`, want: true},
+		{text: `extensions["baz"]`, want: "qux"},
+		{text: `"foo" in extensions`, want: true},
+		{text: `"two" in data.list`, want: true},
+		{text: `1 in [1.0, 2]`, want: true},
+		{text: `[1, "a"]`, want: []interface{}{1.0, "a"}},
+		{text: `has(data.list)`, want: true},
+		{text: `has(extensions.none)`, want: false},
+		{text: `null == null`, want: true},
+
+		// Errors.
+		{text: `1 + "a"`, err: true},
+		{text: `"a" < 1`, err: true},
+		{text: `!1`, err: true},
+		{text: `-"a"`, err: true},
+		{text: `1 && true`, err: true},
+		{text: `true && 1`, err: true},
+		{text: `size(1)`, err: true},
+		{text: `1 / 0`, err: true},
+		{text: `1 % 0`, err: true},
+		{text: `data.list[1.5]`, err: true},
+		{text: `data.list[3]`, err: true},
+		{text: `data.list[-1]`, err: true},
+		{text: `subject[0]`, err: true},
+		{text: `data.none`, err: true},
+		{text: `1 in "a"`, err: true},
+		{text: `double("a")`, err: true},
+		{text: `subject.startsWith(1)`, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			prog, err := Compile(tt.text, testVars)
+			if err != nil {
+				t.Fatalf("failed to compile: %v", err)
+			}
+
+			got, err := prog.Eval(testValues())
+			if (err != nil) != tt.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("result = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalBool(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+		err  bool
+	}{
+		{text: `subject == "日本語"`, want: true},
+		{text: `subject != "日本語"`, want: false},
+		{text: `subject`, err: true},
+		{text: `data.none == 1`, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			prog, err := Compile(tt.text, testVars)
+			if err != nil {
+				t.Fatalf("failed to compile: %v", err)
+			}
+
+			got, err := prog.EvalBool(testValues())
+			if (err != nil) != tt.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("result = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package expr
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenOperator
+)
+
+// dialect is the lexical rules of an expression language.
+type dialect struct {
+	// operators is the list of operators. Longer operators must come
+	// first.
+	operators []string
+	// scanNumber scans a number literal at the beginning of the text and
+	// returns its length. It returns false if the literal is malformed.
+	scanNumber func(text string) (int, bool)
+	// unquote returns the value of the body of string literal.
+	unquote func(body string, quote byte) (string, error)
+}
+
+// celDialect is the lexical rules of CEL.
+var celDialect = &dialect{
+	operators: []string{
+		"==", "!=", "<=", ">=", "&&", "||",
+		"<", ">", "!", "+", "-", "*", "/", "%",
+		"(", ")", "[", "]", ",", ".",
+	},
+	scanNumber: scanNumber,
+	unquote:    unquoteCEL,
+}
+
+// sqlDialect is the lexical rules of CloudEvents SQL.
+var sqlDialect = &dialect{
+	operators: []string{
+		"!=", "<>", "<=", ">=", "||",
+		"=", "<", ">", "+", "-", "*", "/", "%",
+		"(", ")", ",",
+	},
+	scanNumber: scanInteger,
+	unquote:    unquoteSQL,
+}
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+	num  *big.Rat
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of expression"
+	case tokenString:
+		return strconv.Quote(t.text)
+	}
+	return fmt.Sprintf("'%s'", t.text)
+}
+
+// tokenize splits the expression into tokens with the lexical rules of the
+// dialect. Positions of tokens are byte offsets in the expression.
+func tokenize(text string, d *dialect) ([]token, error) {
+	tokens := []token{}
+	i := 0
+
+	for i < len(text) {
+		c, size := utf8.DecodeRuneInString(text[i:])
+
+		switch {
+		case c == utf8.RuneError && size == 1:
+			return nil, fmt.Errorf("invalid UTF-8 encoding at position %d", i)
+
+		case unicode.IsSpace(c):
+			i += size
+
+		case isIdentStart(c):
+			start := i
+			i = scanIdent(text, i)
+			tokens = append(tokens, token{kind: tokenIdent, text: text[start:i], pos: start})
+
+		case isDigit(c):
+			start := i
+			n, ok := d.scanNumber(text[i:])
+			i += n
+			if !ok {
+				// Include the trailing characters in the error message,
+				// such as '1e' of '1ex'.
+				return nil, fmt.Errorf("invalid number at position %d: %s", start, text[start:scanIdent(text, i)])
+			}
+			num, err := parseNumber(text[start:i])
+			if err != nil {
+				return nil, fmt.Errorf("invalid number at position %d: %s", start, text[start:i])
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: text[start:i], pos: start, num: num})
+
+		case c == '"' || c == '\'':
+			start := i
+			s, n, err := scanString(text[i:], d)
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at position %d: %s", start, err)
+			}
+			i += n
+			tokens = append(tokens, token{kind: tokenString, text: s, pos: start})
+
+		default:
+			op := ""
+			for _, o := range d.operators {
+				if strings.HasPrefix(text[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character at position %d: %q", i, c)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: op, pos: i})
+			i += len(op)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(text)}), nil
+}
+
+func isDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c rune) bool {
+	return c == '_' || unicode.IsLetter(c)
+}
+
+func isIdentPart(c rune) bool {
+	return isIdentStart(c) || unicode.IsDigit(c)
+}
+
+// scanIdent returns the end of the identifier that starts at i.
+func scanIdent(text string, i int) int {
+	for i < len(text) {
+		c, size := utf8.DecodeRuneInString(text[i:])
+		if !isIdentPart(c) {
+			break
+		}
+		i += size
+	}
+	return i
+}
+
+// scanNumber scans a number literal at the beginning of the text, such as
+// '12', '1.5' and '1e-5', and returns its length. It returns false if the
+// literal is malformed or followed by an identifier.
+func scanNumber(text string) (int, bool) {
+	digits := func(i int) int {
+		for i < len(text) && isDigit(rune(text[i])) {
+			i++
+		}
+		return i
+	}
+
+	i := digits(0)
+	if i+1 < len(text) && text[i] == '.' && isDigit(rune(text[i+1])) {
+		i = digits(i + 1)
+	}
+	if i < len(text) && (text[i] == 'e' || text[i] == 'E') {
+		j := i + 1
+		if j < len(text) && (text[j] == '+' || text[j] == '-') {
+			j++
+		}
+		if j >= len(text) || !isDigit(rune(text[j])) {
+			return i + 1, false
+		}
+		i = digits(j)
+	}
+
+	if i < len(text) {
+		if c, _ := utf8.DecodeRuneInString(text[i:]); isIdentPart(c) {
+			return i, false
+		}
+	}
+
+	return i, true
+}
+
+// scanInteger scans an integer literal at the beginning of the text and
+// returns its length. It returns false if the literal is followed by an
+// identifier or a fraction.
+func scanInteger(text string) (int, bool) {
+	i := 0
+	for i < len(text) && isDigit(rune(text[i])) {
+		i++
+	}
+
+	if i < len(text) {
+		if c, _ := utf8.DecodeRuneInString(text[i:]); c == '.' || isIdentPart(c) {
+			return i, false
+		}
+	}
+
+	return i, true
+}
+
+// scanString scans a quoted string literal at the beginning of the text and
+// returns the unquoted string and the length of the literal.
+func scanString(text string, d *dialect) (string, int, error) {
+	quote := text[0]
+
+	i := 1
+	for i < len(text) && text[i] != quote {
+		if text[i] == '\\' {
+			i++
+		}
+		i++
+	}
+	if i >= len(text) {
+		return "", 0, fmt.Errorf("string is not terminated")
+	}
+
+	s, err := d.unquote(text[1:i], quote)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return s, i + 1, nil
+}
+
+// unquoteCEL returns the value of string literal of CEL. Escape sequences
+// are the same as Go.
+func unquoteCEL(body string, quote byte) (string, error) {
+	if quote == '\'' {
+		// Convert to a double quoted string for strconv.Unquote.
+		var b strings.Builder
+		for j := 0; j < len(body); j++ {
+			switch {
+			case body[j] == '\\' && j+1 < len(body) && body[j+1] == '\'':
+				b.WriteByte('\'')
+				j++
+			case body[j] == '\\' && j+1 < len(body):
+				b.WriteString(body[j : j+2])
+				j++
+			case body[j] == '"':
+				b.WriteString(`\"`)
+			default:
+				b.WriteByte(body[j])
+			}
+		}
+		body = b.String()
+	}
+
+	return strconv.Unquote(`"` + body + `"`)
+}
+
+// unquoteSQL returns the value of string literal of CloudEvents SQL. Only
+// the quote can be escaped with '\', and other backslashes are kept as is
+// so that they can be used in patterns, such as '\%' of LIKE operator.
+func unquoteSQL(body string, quote byte) (string, error) {
+	var b strings.Builder
+	for j := 0; j < len(body); j++ {
+		if body[j] == '\\' && j+1 < len(body) && body[j+1] == quote {
+			j++
+		}
+		b.WriteByte(body[j])
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,300 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package expr
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// functions is the number of arguments of global functions.
+var functions = map[string]int{
+	"has":    1,
+	"size":   1,
+	"string": 1,
+	"double": 1,
+}
+
+// methods is the number of arguments of methods, excluding the receiver.
+var methods = map[string]int{
+	"startsWith": 1,
+	"endsWith":   1,
+	"contains":   1,
+	"matches":    1,
+	"size":       0,
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	vars   map[string]bool
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the next token if it is one of the operators or
+// keywords.
+func (p *parser) accept(ops ...string) (string, bool) {
+	tok := p.peek()
+	if tok.kind != tokenOperator && tok.kind != tokenIdent {
+		return "", false
+	}
+
+	for _, op := range ops {
+		if tok.text == op {
+			p.next()
+			return op, true
+		}
+	}
+
+	return "", false
+}
+
+func (p *parser) expect(op string) error {
+	tok := p.next()
+	if tok.kind != tokenOperator || tok.text != op {
+		return fmt.Errorf("expected '%s' but got %s at position %d", op, tok, tok.pos)
+	}
+	return nil
+}
+
+// parseExpr parses an expression. The precedence of operators is the
+// same as CEL.
+func (p *parser) parseExpr() (node, error) {
+	return p.parseBinary(0)
+}
+
+// binaryOperators is the list of binary operators ordered by precedence.
+var binaryOperators = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<", "<=", ">", ">=", "in"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) parseBinary(level int) (node, error) {
+	if level >= len(binaryOperators) {
+		return p.parseUnary()
+	}
+
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op, ok := p.accept(binaryOperators[level]...)
+		if !ok {
+			return left, nil
+		}
+
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if op, ok := p.accept("!", "-"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: op, operand: operand}, nil
+	}
+
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (node, error) {
+	n, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op, ok := p.accept(".", "[")
+		if !ok {
+			return n, nil
+		}
+
+		if op == "[" {
+			index, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			n = &indexNode{operand: n, index: index}
+			continue
+		}
+
+		tok := p.next()
+		if tok.kind != tokenIdent {
+			return nil, fmt.Errorf("expected field name but got %s at position %d", tok, tok.pos)
+		}
+
+		if _, ok := p.accept("("); !ok {
+			n = &selectNode{operand: n, field: tok.text}
+			continue
+		}
+
+		args, err := p.parseArgs(")")
+		if err != nil {
+			return nil, err
+		}
+
+		nargs, ok := methods[tok.text]
+		if !ok {
+			return nil, fmt.Errorf("unknown method '%s' at position %d", tok.text, tok.pos)
+		}
+		if len(args) != nargs {
+			return nil, fmt.Errorf("method '%s' takes %d argument(s) at position %d", tok.text, nargs, tok.pos)
+		}
+		if tok.text == "matches" {
+			if lit, ok := args[0].(*literalNode); ok {
+				if s, ok := lit.value.(string); ok {
+					if _, err := regexp.Compile(s); err != nil {
+						return nil, fmt.Errorf("invalid regular expression at position %d: %s", tok.pos, err)
+					}
+				}
+			}
+		}
+
+		n = &callNode{name: tok.text, target: n, args: args}
+	}
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+
+	switch tok.kind {
+	case tokenNumber:
+		return &literalNode{value: tok.num}, nil
+
+	case tokenString:
+		return &literalNode{value: tok.text}, nil
+
+	case tokenIdent:
+		switch tok.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null":
+			return &literalNode{value: nil}, nil
+		case "in":
+			return nil, fmt.Errorf("unexpected %s at position %d", tok, tok.pos)
+		}
+
+		if _, ok := p.accept("("); ok {
+			return p.parseCall(tok)
+		}
+
+		if !p.vars[tok.text] {
+			return nil, fmt.Errorf("undeclared reference to '%s' at position %d", tok.text, tok.pos)
+		}
+		return &identNode{name: tok.text}, nil
+
+	case tokenOperator:
+		switch tok.text {
+		case "(":
+			n, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return n, nil
+
+		case "[":
+			items, err := p.parseArgs("]")
+			if err != nil {
+				return nil, err
+			}
+			return &listNode{items: items}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unexpected %s at position %d", tok, tok.pos)
+}
+
+// parseCall parses the arguments of the global function.
+func (p *parser) parseCall(name token) (node, error) {
+	args, err := p.parseArgs(")")
+	if err != nil {
+		return nil, err
+	}
+
+	nargs, ok := functions[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function '%s' at position %d", name.text, name.pos)
+	}
+	if len(args) != nargs {
+		return nil, fmt.Errorf("function '%s' takes %d argument(s) at position %d", name.text, nargs, name.pos)
+	}
+
+	if name.text == "has" {
+		switch args[0].(type) {
+		case *selectNode, *indexNode:
+		default:
+			return nil, fmt.Errorf("argument of 'has' must be a field selection at position %d", name.pos)
+		}
+	}
+
+	return &callNode{name: name.text, args: args}, nil
+}
+
+// parseArgs parses the comma separated expressions until the closing
+// operator.
+func (p *parser) parseArgs(end string) ([]node, error) {
+	args := []node{}
+	if _, ok := p.accept(end); ok {
+		return args, nil
+	}
+
+	for {
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+
+		if _, ok := p.accept(end); ok {
+			return args, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,732 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package expr
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// sqlFunctions is the number of arguments of CloudEvents SQL functions.
+// Negative number means the minimum number of variadic arguments.
+var sqlFunctions = map[string]int{
+	"LENGTH":    1,
+	"CONCAT":    -1,
+	"CONCAT_WS": -2,
+	"LOWER":     1,
+	"UPPER":     1,
+	"TRIM":      1,
+	"LEFT":      2,
+	"RIGHT":     2,
+	"SUBSTRING": -2,
+	"ABS":       1,
+	"INT":       1,
+	"BOOL":      1,
+	"STRING":    1,
+	"IS_INT":    1,
+	"IS_BOOL":   1,
+}
+
+// sqlKeywords is the list of reserved keywords of CloudEvents SQL.
+var sqlKeywords = map[string]bool{
+	"AND":    true,
+	"OR":     true,
+	"XOR":    true,
+	"NOT":    true,
+	"LIKE":   true,
+	"IN":     true,
+	"EXISTS": true,
+	"TRUE":   true,
+	"FALSE":  true,
+}
+
+// CompileSQL parses the expression written in CloudEvents SQL (CESQL).
+// Identifiers refer to the variables, and referring to the missing variable
+// is an error at evaluation. Values are strings, integers and booleans, and
+// they are implicitly converted by operators as defined in CESQL.
+func CompileSQL(text string) (*Program, error) {
+	tokens, err := tokenize(text, sqlDialect)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseSQLOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %s at position %d", tok, tok.pos)
+	}
+
+	return &Program{root: root}, nil
+}
+
+// acceptKeyword consumes the next token if it is the keyword. Keywords are
+// case insensitive.
+func (p *parser) acceptKeyword(keyword string) bool {
+	tok := p.peek()
+	if tok.kind == tokenIdent && strings.EqualFold(tok.text, keyword) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) parseSQLOr() (node, error) {
+	return p.parseSQLLogical("OR", p.parseSQLXor)
+}
+
+func (p *parser) parseSQLXor() (node, error) {
+	return p.parseSQLLogical("XOR", p.parseSQLAnd)
+}
+
+func (p *parser) parseSQLAnd() (node, error) {
+	return p.parseSQLLogical("AND", p.parseSQLNot)
+}
+
+func (p *parser) parseSQLLogical(op string, operand func() (node, error)) (node, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.acceptKeyword(op) {
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &sqlBinaryNode{op: op, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseSQLNot() (node, error) {
+	if p.acceptKeyword("NOT") {
+		operand, err := p.parseSQLNot()
+		if err != nil {
+			return nil, err
+		}
+		return &sqlUnaryNode{op: "NOT", operand: operand}, nil
+	}
+
+	return p.parseSQLComparison()
+}
+
+// parseSQLComparison parses the comparison, LIKE and IN operators. They are
+// left-associative, such as "subject = 'main' = TRUE".
+func (p *parser) parseSQLComparison() (node, error) {
+	left, err := p.parseSQLArithmetic(0)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if op, ok := p.accept("=", "!=", "<>", "<", "<=", ">", ">="); ok {
+			right, err := p.parseSQLArithmetic(0)
+			if err != nil {
+				return nil, err
+			}
+			left = &sqlBinaryNode{op: op, left: left, right: right}
+			continue
+		}
+
+		not := p.acceptKeyword("NOT")
+
+		switch {
+		case p.acceptKeyword("LIKE"):
+			tok := p.next()
+			if tok.kind != tokenString {
+				return nil, fmt.Errorf("expected pattern string but got %s at position %d", tok, tok.pos)
+			}
+			left = &sqlLikeNode{operand: left, pattern: likePattern(tok.text), not: not}
+			continue
+
+		case p.acceptKeyword("IN"):
+			if err := p.expect("("); err != nil {
+				return nil, err
+			}
+			items, err := p.parseSQLArgs()
+			if err != nil {
+				return nil, err
+			}
+			left = &sqlInNode{operand: left, items: items, not: not}
+			continue
+		}
+
+		if not {
+			tok := p.peek()
+			return nil, fmt.Errorf("expected LIKE or IN but got %s at position %d", tok, tok.pos)
+		}
+
+		return left, nil
+	}
+}
+
+// sqlArithmeticOperators is the list of arithmetic operators ordered by
+// precedence. '||' concatenates strings as in standard SQL.
+var sqlArithmeticOperators = [][]string{
+	{"||"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) parseSQLArithmetic(level int) (node, error) {
+	if level >= len(sqlArithmeticOperators) {
+		return p.parseSQLUnary()
+	}
+
+	left, err := p.parseSQLArithmetic(level + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op, ok := p.accept(sqlArithmeticOperators[level]...)
+		if !ok {
+			return left, nil
+		}
+
+		right, err := p.parseSQLArithmetic(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &sqlBinaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseSQLUnary() (node, error) {
+	if _, ok := p.accept("-"); ok {
+		operand, err := p.parseSQLUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &sqlUnaryNode{op: "-", operand: operand}, nil
+	}
+
+	return p.parseSQLPrimary()
+}
+
+func (p *parser) parseSQLPrimary() (node, error) {
+	tok := p.next()
+
+	switch tok.kind {
+	case tokenNumber:
+		n, err := strconv.ParseInt(tok.text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer at position %d: %s", tok.pos, tok.text)
+		}
+		return &literalNode{value: n}, nil
+
+	case tokenString:
+		return &literalNode{value: tok.text}, nil
+
+	case tokenIdent:
+		keyword := strings.ToUpper(tok.text)
+		switch keyword {
+		case "TRUE":
+			return &literalNode{value: true}, nil
+		case "FALSE":
+			return &literalNode{value: false}, nil
+		case "EXISTS":
+			name := p.next()
+			if name.kind != tokenIdent || sqlKeywords[strings.ToUpper(name.text)] {
+				return nil, fmt.Errorf("expected attribute name but got %s at position %d", name, name.pos)
+			}
+			return &sqlExistsNode{name: name.text}, nil
+		}
+
+		if _, ok := p.accept("("); ok {
+			nargs, ok := sqlFunctions[keyword]
+			if !ok {
+				return nil, fmt.Errorf("unknown function '%s' at position %d", tok.text, tok.pos)
+			}
+
+			args, err := p.parseSQLArgs()
+			if err != nil {
+				return nil, err
+			}
+			if (nargs >= 0 && len(args) != nargs) || (nargs < 0 && len(args) < -nargs) {
+				return nil, fmt.Errorf("invalid number of arguments of '%s' at position %d", tok.text, tok.pos)
+			}
+			if keyword == "SUBSTRING" && len(args) > 3 {
+				return nil, fmt.Errorf("invalid number of arguments of '%s' at position %d", tok.text, tok.pos)
+			}
+
+			return &sqlCallNode{name: keyword, args: args}, nil
+		}
+
+		if sqlKeywords[keyword] {
+			return nil, fmt.Errorf("unexpected %s at position %d", tok, tok.pos)
+		}
+		return &identNode{name: tok.text}, nil
+
+	case tokenOperator:
+		if tok.text == "(" {
+			n, err := p.parseSQLOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return n, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unexpected %s at position %d", tok, tok.pos)
+}
+
+// parseSQLArgs parses the comma separated expressions until ')'.
+func (p *parser) parseSQLArgs() ([]node, error) {
+	args := []node{}
+	if _, ok := p.accept(")"); ok {
+		return args, nil
+	}
+
+	for {
+		arg, err := p.parseSQLOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+
+		if _, ok := p.accept(")"); ok {
+			return args, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// likePattern converts the pattern of LIKE operator to a regular
+// expression. '%' matches any characters and '_' matches a character, and
+// they can be escaped with '\'.
+func likePattern(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("(?s)^")
+
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; {
+		case c == '\\' && i+1 < len(runes):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(runes[i])))
+		case c == '%':
+			b.WriteString(".*")
+		case c == '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+type sqlExistsNode struct {
+	name string
+}
+
+func (n *sqlExistsNode) eval(vars map[string]interface{}) (interface{}, error) {
+	_, ok := vars[n.name]
+	return ok, nil
+}
+
+type sqlUnaryNode struct {
+	op      string
+	operand node
+}
+
+func (n *sqlUnaryNode) eval(vars map[string]interface{}) (interface{}, error) {
+	v, err := n.operand.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.op == "NOT" {
+		b, err := toSQLBool(v)
+		if err != nil {
+			return nil, err
+		}
+		return !b, nil
+	}
+
+	i, err := toSQLInt(v)
+	if err != nil {
+		return nil, err
+	}
+	return -i, nil
+}
+
+type sqlBinaryNode struct {
+	op    string
+	left  node
+	right node
+}
+
+func (n *sqlBinaryNode) eval(vars map[string]interface{}) (interface{}, error) {
+	left, err := n.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "AND", "OR", "XOR":
+		l, err := toSQLBool(left)
+		if err != nil {
+			return nil, err
+		}
+		if (n.op == "AND" && !l) || (n.op == "OR" && l) {
+			return l, nil
+		}
+
+		right, err := n.right.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		r, err := toSQLBool(right)
+		if err != nil {
+			return nil, err
+		}
+		if n.op == "XOR" {
+			return l != r, nil
+		}
+		return r, nil
+	}
+
+	right, err := n.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "||":
+		return toSQLString(left) + toSQLString(right), nil
+	case "=":
+		return sqlEqual(left, right)
+	case "!=", "<>":
+		eq, err := sqlEqual(left, right)
+		if err != nil {
+			return nil, err
+		}
+		return !eq, nil
+	}
+
+	l, err := toSQLInt(left)
+	if err != nil {
+		return nil, err
+	}
+	r, err := toSQLInt(right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "<":
+		return l < r, nil
+	case "<=":
+		return l <= r, nil
+	case ">":
+		return l > r, nil
+	case ">=":
+		return l >= r, nil
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return l / r, nil
+	case "%":
+		if r == 0 {
+			return nil, fmt.Errorf("modulus by zero")
+		}
+		return l % r, nil
+	}
+
+	return nil, fmt.Errorf("unknown operator: %s", n.op)
+}
+
+type sqlLikeNode struct {
+	operand node
+	pattern *regexp.Regexp
+	not     bool
+}
+
+func (n *sqlLikeNode) eval(vars map[string]interface{}) (interface{}, error) {
+	v, err := n.operand.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	return n.pattern.MatchString(toSQLString(v)) != n.not, nil
+}
+
+type sqlInNode struct {
+	operand node
+	items   []node
+	not     bool
+}
+
+func (n *sqlInNode) eval(vars map[string]interface{}) (interface{}, error) {
+	v, err := n.operand.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range n.items {
+		iv, err := item.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+
+		eq, err := sqlEqual(v, iv)
+		if err != nil {
+			return nil, err
+		}
+		if eq {
+			return !n.not, nil
+		}
+	}
+
+	return n.not, nil
+}
+
+type sqlCallNode struct {
+	name string
+	args []node
+}
+
+func (n *sqlCallNode) eval(vars map[string]interface{}) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+
+	switch n.name {
+	case "LENGTH":
+		return int64(len([]rune(toSQLString(args[0])))), nil
+	case "CONCAT":
+		return sqlConcat("", args), nil
+	case "CONCAT_WS":
+		return sqlConcat(toSQLString(args[0]), args[1:]), nil
+	case "LOWER":
+		return strings.ToLower(toSQLString(args[0])), nil
+	case "UPPER":
+		return strings.ToUpper(toSQLString(args[0])), nil
+	case "TRIM":
+		return strings.TrimSpace(toSQLString(args[0])), nil
+	case "LEFT", "RIGHT":
+		s := []rune(toSQLString(args[0]))
+		length, err := toSQLInt(args[1])
+		if err != nil {
+			return nil, err
+		}
+		if length < 0 {
+			return nil, fmt.Errorf("length of %s must not be negative", n.name)
+		}
+		if length > int64(len(s)) {
+			length = int64(len(s))
+		}
+		if n.name == "LEFT" {
+			return string(s[:length]), nil
+		}
+		return string(s[int64(len(s))-length:]), nil
+	case "SUBSTRING":
+		return sqlSubstring(args)
+	case "ABS":
+		i, err := toSQLInt(args[0])
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 {
+			return -i, nil
+		}
+		return i, nil
+	case "INT":
+		return toSQLInt(args[0])
+	case "BOOL":
+		return toSQLBool(args[0])
+	case "STRING":
+		return toSQLString(args[0]), nil
+	case "IS_INT":
+		_, err := toSQLInt(args[0])
+		return err == nil, nil
+	case "IS_BOOL":
+		_, err := toSQLBool(args[0])
+		return err == nil, nil
+	}
+
+	return nil, fmt.Errorf("unknown function: %s", n.name)
+}
+
+// sqlConcat concatenates the values with the separator.
+func sqlConcat(sep string, values []interface{}) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = toSQLString(v)
+	}
+	return strings.Join(s, sep)
+}
+
+// sqlSubstring returns the substring from the 1-based position. Negative
+// position counts from the end of the string.
+func sqlSubstring(args []interface{}) (interface{}, error) {
+	s := []rune(toSQLString(args[0]))
+
+	pos, err := toSQLInt(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	start := pos - 1
+	if pos < 0 {
+		start = int64(len(s)) + pos
+	}
+	if pos == 0 || start < 0 || start > int64(len(s)) {
+		return nil, fmt.Errorf("position of SUBSTRING is out of range: %d", pos)
+	}
+
+	end := int64(len(s))
+	if len(args) == 3 {
+		length, err := toSQLInt(args[2])
+		if err != nil {
+			return nil, err
+		}
+		if length < 0 {
+			return nil, fmt.Errorf("length of SUBSTRING must not be negative")
+		}
+		if start+length < end {
+			end = start + length
+		}
+	}
+
+	return string(s[start:end]), nil
+}
+
+// sqlEqual compares the values. If the types of values are different, the
+// string value is converted to the type of the other value.
+func sqlEqual(a, b interface{}) (bool, error) {
+	a, b = toSQLValue(a), toSQLValue(b)
+
+	switch av := a.(type) {
+	case int64:
+		bv, err := toSQLInt(b)
+		if err != nil {
+			return false, err
+		}
+		return av == bv, nil
+	case bool:
+		bv, err := toSQLBool(b)
+		if err != nil {
+			return false, err
+		}
+		return av == bv, nil
+	}
+
+	switch bv := b.(type) {
+	case int64:
+		av, err := toSQLInt(a)
+		if err != nil {
+			return false, err
+		}
+		return av == bv, nil
+	case bool:
+		av, err := toSQLBool(a)
+		if err != nil {
+			return false, err
+		}
+		return av == bv, nil
+	}
+
+	return toSQLString(a) == toSQLString(b), nil
+}
+
+// toSQLValue converts the variable to one of string, int64 and bool.
+func toSQLValue(v interface{}) interface{} {
+	switch n := normalize(v).(type) {
+	case *big.Rat:
+		if n.IsInt() && n.Num().IsInt64() {
+			return n.Num().Int64()
+		}
+		return formatNumber(n)
+	case float64:
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	case string, int64, bool:
+		return n
+	case nil:
+		return ""
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func toSQLInt(v interface{}) (int64, error) {
+	switch n := toSQLValue(v).(type) {
+	case int64:
+		return n, nil
+	case string:
+		i, err := strconv.ParseInt(strings.TrimSpace(n), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("cannot convert %q to integer", n)
+		}
+		return i, nil
+	}
+	return 0, fmt.Errorf("cannot convert %v to integer", v)
+}
+
+func toSQLBool(v interface{}) (bool, error) {
+	switch b := toSQLValue(v).(type) {
+	case bool:
+		return b, nil
+	case string:
+		switch strings.ToLower(b) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		return false, fmt.Errorf("cannot convert %q to boolean", b)
+	}
+	return false, fmt.Errorf("cannot convert %v to boolean", v)
+}
+
+func toSQLString(v interface{}) string {
+	switch s := toSQLValue(v).(type) {
+	case string:
+		return s
+	case int64:
+		return strconv.FormatInt(s, 10)
+	case bool:
+		return strconv.FormatBool(s)
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,280 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package expr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func sqlAttributes() map[string]interface{} {
+	return map[string]interface{}{
+		"specversion": "1.0",
+		"id":          "123",
+		"source":      "https://github.com/summerwind/eventreactor",
+		"type":        "com.github.push",
+		"subject":     "main",
+		"sequence":    "5",
+		"enabled":     "true",
+		"comment":     "50% off",
+	}
+}
+
+func TestTokenizeSQL(t *testing.T) {
+	type tok struct {
+		kind tokenKind
+		text string
+	}
+
+	tests := []struct {
+		text   string
+		tokens []tok
+		err    bool
+	}{
+		{
+			text:   `a = 'b'`,
+			tokens: []tok{{tokenIdent, "a"}, {tokenOperator, "="}, {tokenString, "b"}},
+		},
+		{
+			text:   `a <> b != c <= d`,
+			tokens: []tok{{tokenIdent, "a"}, {tokenOperator, "<>"}, {tokenIdent, "b"}, {tokenOperator, "!="}, {tokenIdent, "c"}, {tokenOperator, "<="}, {tokenIdent, "d"}},
+		},
+		{
+			text:   `'a' || "b"`,
+			tokens: []tok{{tokenString, "a"}, {tokenOperator, "||"}, {tokenString, "b"}},
+		},
+		{
+			text:   `'it\'s' "say \"hi\""`,
+			tokens: []tok{{tokenString, "it's"}, {tokenString, `say "hi"`}},
+		},
+		{
+			text:   `'\%\_\n' '日本'`,
+			tokens: []tok{{tokenString, `\%\_\n`}, {tokenString, "日本"}},
+		},
+		{
+			text:   `-12`,
+			tokens: []tok{{tokenOperator, "-"}, {tokenNumber, "12"}},
+		},
+		{text: `1.5`, err: true},
+		{text: `1e5`, err: true},
+		{text: `a.b`, err: true},
+		{text: `!a`, err: true},
+		{text: `a == b`, tokens: []tok{{tokenIdent, "a"}, {tokenOperator, "="}, {tokenOperator, "="}, {tokenIdent, "b"}}},
+		{text: `a[0]`, err: true},
+		{text: `a && b`, err: true},
+		{text: `'abc`, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			tokens, err := tokenize(tt.text, sqlDialect)
+			if (err != nil) != tt.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.err {
+				return
+			}
+
+			got := []tok{}
+			for _, tk := range tokens {
+				if tk.kind != tokenEOF {
+					got = append(got, tok{tk.kind, tk.text})
+				}
+			}
+			if !reflect.DeepEqual(got, tt.tokens) {
+				t.Errorf("tokens = %v, want %v", got, tt.tokens)
+			}
+		})
+	}
+}
+
+// TestCELIsNotAffectedBySQL ensures that the lexical rules of CloudEvents
+// SQL are not applied to CEL.
+func TestCELIsNotAffectedBySQL(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+		err  bool
+	}{
+		{text: `a = b`, err: true},
+		{text: `'\%'`, err: true},
+		{text: `'\q'`, err: true},
+		{text: `"a\nb"`, want: "a\nb"},
+		{text: `'a\nb'`, want: "a\nb"},
+		{text: `'it\'s'`, want: "it's"},
+		{text: `'say "hi"'`, want: `say "hi"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			tokens, err := tokenize(tt.text, celDialect)
+			if (err != nil) != tt.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.err {
+				return
+			}
+			if tokens[0].kind != tokenString || tokens[0].text != tt.want {
+				t.Errorf("token = %v, want %q", tokens[0], tt.want)
+			}
+		})
+	}
+	for _, text := range []string{`a = b`, `a <> b`} {
+		if _, err := Compile(text, []string{"a"}); err == nil {
+			t.Errorf("%s: expected error", text)
+		}
+	}
+}
+
+func TestCompileSQLError(t *testing.T) {
+	tests := []string{
+		`NOT`,
+		`subject NOT 'main'`,
+		`subject LIKE 1`,
+		`subject IN 'main'`,
+		`subject IN ('main'`,
+		`EXISTS TRUE`,
+		`EXISTS 'subject'`,
+		`LENGTH()`,
+		`LENGTH('a', 'b')`,
+		`CONCAT_WS(',')`,
+		`SUBSTRING('a', 1, 2, 3)`,
+		`UNKNOWN(subject)`,
+		`AND`,
+		`subject =`,
+		`(subject = 'main'`,
+		`subject = 'main' subject`,
+		`99999999999999999999`,
+	}
+
+	for _, text := range tests {
+		t.Run(text, func(t *testing.T) {
+			if _, err := CompileSQL(text); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
+
+func TestEvalSQL(t *testing.T) {
+	tests := []struct {
+		text string
+		want interface{}
+		err  bool
+	}{
+		// Examples of CloudEvents SQL specification.
+		{text: `EXISTS subject`, want: true},
+		{text: `EXISTS dataschema`, want: false},
+		{text: `type = 'com.github.push'`, want: true},
+		{text: `type LIKE 'com.github.%'`, want: true},
+		{text: `source LIKE 'https://github.com/%' AND type = 'com.github.push'`, want: true},
+		{text: `subject IN ('main', 'develop')`, want: true},
+		{text: `subject NOT IN ('main', 'develop')`, want: false},
+		{text: `type NOT LIKE '%.pull_request'`, want: true},
+		{text: `(type = 'com.github.push' OR type = 'com.github.pull_request') AND subject = 'main'`, want: true},
+		{text: `sequence > 3`, want: true},
+		{text: `sequence + 1 = 6`, want: true},
+		{text: `specversion = '1.0'`, want: true},
+
+		// Literals and case insensitivity.
+		{text: `true`, want: true},
+		{text: `false or TRUE`, want: true},
+		{text: `"double quoted" = 'double quoted'`, want: true},
+		{text: `'it\'s'`, want: "it's"},
+		{text: `-5`, want: int64(-5)},
+
+		// Operators and precedence.
+		{text: `1 + 2 * 3`, want: int64(7)},
+		{text: `(1 + 2) * 3`, want: int64(9)},
+		{text: `7 / 2`, want: int64(3)},
+		{text: `-7 % 3`, want: int64(-1)},
+		{text: `10 - 4 - 3`, want: int64(3)},
+		{text: `TRUE XOR TRUE`, want: false},
+		{text: `TRUE OR FALSE AND FALSE`, want: true},
+		{text: `NOT TRUE OR TRUE`, want: true},
+		{text: `NOT subject = 'main'`, want: false},
+		{text: `subject = 'main' = TRUE`, want: true},
+		{text: `subject = 'develop' = FALSE`, want: true},
+		{text: `1 < 2 = TRUE`, want: true},
+		{text: `subject <> 'main'`, want: false},
+		{text: `subject != 'develop'`, want: true},
+		{text: `'a' || 'b'`, want: "ab"},
+		{text: `subject || '-' || id = 'main-123'`, want: true},
+		{text: `'n' || 1 + 2`, want: "n3"},
+
+		// LIKE patterns.
+		{text: `comment LIKE '50\% %'`, want: true},
+		{text: `comment LIKE '5_% off'`, want: true},
+		{text: `comment LIKE '50\_%'`, want: false},
+		{text: `subject LIKE 'MAIN'`, want: false},
+
+		// Implicit casts.
+		{text: `sequence = 5`, want: true},
+		{text: `5 = sequence`, want: true},
+		{text: `enabled = TRUE`, want: true},
+		{text: `'10' > 9`, want: true},
+		{text: `id IN (123, 456)`, want: true},
+
+		// Functions.
+		{text: `LENGTH('abc')`, want: int64(3)},
+		{text: `length('日本語')`, want: int64(3)},
+		{text: `CONCAT('a', 'b', 'c')`, want: "abc"},
+		{text: `CONCAT_WS(',', 'a', 'b')`, want: "a,b"},
+		{text: `LOWER('ABC')`, want: "abc"},
+		{text: `UPPER('abc')`, want: "ABC"},
+		{text: `TRIM('  a  ')`, want: "a"},
+		{text: `LEFT('abc', 2)`, want: "ab"},
+		{text: `RIGHT('abc', 2)`, want: "bc"},
+		{text: `LEFT('abc', 5)`, want: "abc"},
+		{text: `SUBSTRING('abcdef', 2)`, want: "bcdef"},
+		{text: `SUBSTRING('abcdef', 2, 3)`, want: "bcd"},
+		{text: `SUBSTRING('abcdef', -2)`, want: "ef"},
+		{text: `ABS(-3)`, want: int64(3)},
+		{text: `INT('12')`, want: int64(12)},
+		{text: `BOOL('false')`, want: false},
+		{text: `STRING(12)`, want: "12"},
+		{text: `IS_INT(sequence)`, want: true},
+		{text: `IS_BOOL(subject)`, want: false},
+
+		// Errors.
+		{text: `unknown = 'a'`, err: true},
+		{text: `subject = 1`, err: true},
+		{text: `subject AND TRUE`, err: true},
+		{text: `1 / 0`, err: true},
+		{text: `1 % 0`, err: true},
+		{text: `LEFT('abc', -1)`, err: true},
+		{text: `SUBSTRING('abc', 0)`, err: true},
+		{text: `INT('a')`, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			prog, err := CompileSQL(tt.text)
+			if err != nil {
+				t.Fatalf("failed to compile: %v", err)
+			}
+
+			got, err := prog.Eval(sqlAttributes())
+			if (err != nil) != tt.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("result = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package template provides the template engine for resource templates of
+// subscriptions. Resource templates use '((' and '))' as delimiters so that
+// they do not conflict with other template engines used in resources.
+package template
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"text/template"
+)
+
+const (
+	leftDelim  = "(("
+	rightDelim = "))"
+)
+
+// maxGunzipSize is the maximum size of data decompressed by gunzip, so that
+// compressed payloads cannot exhaust the memory.
+const maxGunzipSize = 10 * 1024 * 1024
+
+// funcs is the set of helper functions available in resource templates.
+var funcs = template.FuncMap{
+	"b64enc": b64enc,
+	"b64dec": b64dec,
+	"gunzip": gunzip,
+}
+
+// New returns a new template with the delimiters and the helper functions
+// of resource template.
+func New(name string) *template.Template {
+	return template.New(name).Delims(leftDelim, rightDelim).Funcs(funcs)
+}
+
+// Validate parses the text as a resource template and returns an error
+// if it is invalid.
+func Validate(text string) error {
+	_, err := New("validate").Parse(text)
+	return err
+}
+
+// ValidateObject parses each string value and key in the object as a
+// resource template and returns an error if any of them is invalid.
+func ValidateObject(obj map[string]interface{}) error {
+	_, err := walk(obj, func(text string) (string, error) {
+		return text, Validate(text)
+	})
+	return err
+}
+
+// ExecuteObject returns a copy of the object in which each string value and
+// key is rendered as a resource template with the data. Strings are
+// rendered separately, so that the values in the data cannot change the
+// structure of the object.
+func ExecuteObject(obj map[string]interface{}, data interface{}) (map[string]interface{}, error) {
+	v, err := walk(obj, func(text string) (string, error) {
+		tmpl, err := New("object").Parse(text)
+		if err != nil {
+			return "", err
+		}
+
+		buf := bytes.NewBuffer([]byte{})
+		if err := tmpl.Execute(buf, data); err != nil {
+			return "", err
+		}
+
+		return buf.String(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(map[string]interface{}), nil
+}
+
+// walk returns a copy of the value in which each string value and key is
+// replaced with the result of fn.
+func walk(v interface{}, fn func(string) (string, error)) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		return fn(v)
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			k, err := fn(key)
+			if err != nil {
+				return nil, err
+			}
+			m[k], err = walk(val, fn)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i := range v {
+			var err error
+			list[i], err = walk(v[i], fn)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	}
+
+	return v, nil
+}
+
+// b64enc returns the base64 encoded string.
+func b64enc(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+// b64dec returns the string decoded from base64.
+func b64dec(s string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// gunzip returns the string decompressed from gzip. An error is returned if
+// the decompressed data exceeds maxGunzipSize.
+func gunzip(s string) (string, error) {
+	r, err := gzip.NewReader(bytes.NewBufferString(s))
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	b, err := ioutil.ReadAll(io.LimitReader(r, maxGunzipSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(b) > maxGunzipSize {
+		return "", fmt.Errorf("decompressed data exceeds %d bytes", maxGunzipSize)
+	}
+
+	return string(b), nil
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2020 The Event Reactor authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package template
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+)
+
+func compress(t *testing.T, data []byte) string {
+	buf := bytes.NewBuffer([]byte{})
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestGunzip(t *testing.T) {
+	got, err := gunzip(compress(t, []byte("hello")))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != "hello" {
+		t.Errorf("got %q, want %q", got, "hello")
+	}
+
+	limit := bytes.Repeat([]byte("a"), maxGunzipSize)
+	if _, err := gunzip(compress(t, limit)); err != nil {
+		t.Errorf("unexpected error for data of the maximum size: %s", err)
+	}
+
+	bomb := compress(t, append(limit, 'a'))
+	if _, err := gunzip(bomb); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("got %v, want size error", err)
+	}
+
+	if _, err := gunzip("not gzip"); err == nil {
+		t.Error("expected error for invalid data")
+	}
+}